@@ -0,0 +1,194 @@
+package coder
+
+import (
+	"errors"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrNotAcceptable        = errors.New("not acceptable")
+)
+
+// Registry holds several Coders keyed by media type and chooses one of them
+// by the Content-Type or Accept header values.
+type Registry struct {
+	mu     sync.RWMutex
+	def    Coder
+	coders []Coder
+}
+
+// NewRegistry returns a new Registry.
+// The default Coder is used when the header value is empty, it is also registered by its media type.
+func NewRegistry(def Coder, coders ...Coder) *Registry {
+	r := &Registry{def: def}
+	r.Register(def)
+	for _, c := range coders {
+		r.Register(c)
+	}
+	return r
+}
+
+// Register adds the Coder to the Registry, replacing the Coder with the same media type.
+// Coders without a content type are not registered.
+func (r *Registry) Register(c Coder) {
+	mediaType := parseMediaType(c.ContentType())
+	if mediaType == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.coders {
+		if parseMediaType(r.coders[i].ContentType()) == mediaType {
+			r.coders[i] = c
+			return
+		}
+	}
+	r.coders = append(r.coders, c)
+}
+
+// Default returns the default Coder.
+func (r *Registry) Default() Coder {
+	return r.def
+}
+
+// Lookup returns the Coder registered for the media type of the Content-Type header value.
+// If contentType is empty, the default Coder is returned.
+func (r *Registry) Lookup(contentType string) (Coder, error) {
+	if strings.TrimSpace(contentType) == "" {
+		return r.def, nil
+	}
+
+	mediaType := parseMediaType(contentType)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, c := range r.coders {
+		if parseMediaType(c.ContentType()) == mediaType {
+			return c, nil
+		}
+	}
+
+	return nil, ErrUnsupportedMediaType
+}
+
+// Negotiate returns the Coder that best matches the Accept header value.
+// Media ranges are weighted by their q-values, the most specific range matching a Coder defines its weight.
+// Coders with equal weight are preferred in the order of registration, the default Coder comes first.
+// If accept is empty, the default Coder is returned.
+func (r *Registry) Negotiate(accept string) (Coder, error) {
+	if strings.TrimSpace(accept) == "" {
+		return r.def, nil
+	}
+
+	ranges := parseAccept(accept)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var best Coder
+	var bestQ float64
+	for _, c := range r.coders {
+		if q := ranges.quality(parseMediaType(c.ContentType())); q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+
+	if best == nil {
+		return nil, ErrNotAcceptable
+	}
+
+	return best, nil
+}
+
+// Accept returns the Accept header value listing all registered media types.
+func (r *Registry) Accept() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]string, 0, len(r.coders))
+	for _, c := range r.coders {
+		types = append(types, parseMediaType(c.ContentType()))
+	}
+
+	return strings.Join(types, ", ")
+}
+
+func parseMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mediaType
+}
+
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// specificity returns how specific the range is: 2 — type/subtype, 1 — type/*, 0 — */*.
+func (m mediaRange) specificity() int {
+	switch {
+	case m.typ == "*":
+		return 0
+	case m.subtype == "*":
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (m mediaRange) match(typ, subtype string) bool {
+	return (m.typ == "*" || m.typ == typ) && (m.subtype == "*" || m.subtype == subtype)
+}
+
+type mediaRanges []mediaRange
+
+func parseAccept(accept string) mediaRanges {
+	var ranges mediaRanges
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		typ, subtype, ok := strings.Cut(mediaType, "/")
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+
+		ranges = append(ranges, mediaRange{typ: typ, subtype: subtype, q: q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+
+	return ranges
+}
+
+// quality returns the q-value of the most specific range matching the media type.
+func (ranges mediaRanges) quality(mediaType string) float64 {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+	for _, m := range ranges {
+		if m.match(typ, subtype) {
+			return m.q
+		}
+	}
+	return 0
+}
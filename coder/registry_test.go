@@ -0,0 +1,115 @@
+package coder_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/easy-techno-lab/proton/coder"
+)
+
+var (
+	cdrJSON = coder.NewCoder("application/json", json.Marshal, json.Unmarshal, false)
+	cdrXML  = coder.NewCoder("application/xml", xml.Marshal, xml.Unmarshal, false)
+	cdrText = coder.NewCoder("text/plain; charset=utf-8", json.Marshal, json.Unmarshal, false)
+)
+
+func TestRegistry_Negotiate(t *testing.T) {
+	registry := coder.NewRegistry(cdrJSON, cdrXML, cdrText)
+
+	var tests = []struct {
+		name   string
+		accept string
+		output coder.Coder
+		err    error
+	}{
+		{
+			name:   "empty accept",
+			output: cdrJSON,
+		},
+		{
+			name:   "exact match",
+			accept: "application/xml",
+			output: cdrXML,
+		},
+		{
+			name:   "q-values",
+			accept: "application/json;q=0.5, application/xml;q=0.8",
+			output: cdrXML,
+		},
+		{
+			name:   "type wildcard",
+			accept: "text/*",
+			output: cdrText,
+		},
+		{
+			name:   "any wildcard prefers default",
+			accept: "*/*",
+			output: cdrJSON,
+		},
+		{
+			name:   "specific range overrides wildcard",
+			accept: "application/*;q=0.9, application/json;q=0.1",
+			output: cdrXML,
+		},
+		{
+			name:   "excluded with q=0",
+			accept: "application/json;q=0, */*;q=0.1",
+			output: cdrXML,
+		},
+		{
+			name:   "not acceptable",
+			accept: "image/png",
+			err:    coder.ErrNotAcceptable,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cdr, err := registry.Negotiate(test.accept)
+			equal(t, test.err, err)
+			equal(t, test.output, cdr)
+		})
+	}
+}
+
+func TestRegistry_Lookup(t *testing.T) {
+	registry := coder.NewRegistry(cdrJSON, cdrXML, cdrText)
+
+	var tests = []struct {
+		name        string
+		contentType string
+		output      coder.Coder
+		err         error
+	}{
+		{
+			name:   "empty content type",
+			output: cdrJSON,
+		},
+		{
+			name:        "media type with parameters",
+			contentType: "text/plain; charset=utf-8",
+			output:      cdrText,
+		},
+		{
+			name:        "case insensitive",
+			contentType: "Application/XML",
+			output:      cdrXML,
+		},
+		{
+			name:        "unsupported media type",
+			contentType: "application/protobuf",
+			err:         coder.ErrUnsupportedMediaType,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cdr, err := registry.Lookup(test.contentType)
+			equal(t, test.err, err)
+			equal(t, test.output, cdr)
+		})
+	}
+
+	equal(t, "application/json, application/xml, text/plain", registry.Accept())
+}
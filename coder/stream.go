@@ -0,0 +1,120 @@
+package coder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// maxDebugBytes is the maximum number of bytes of a stream printed to the debug log.
+const maxDebugBytes = 1 << 14 // 16KiB
+
+// A StreamEncoder writes encoded values to the stream it was created for, like *json.Encoder.
+type StreamEncoder interface {
+	Encode(v any) error
+}
+
+// A StreamDecoder reads encoded values from the stream it was created for, like *json.Decoder.
+type StreamDecoder interface {
+	Decode(v any) error
+}
+
+type streamEncoder struct {
+	f   func(w io.Writer) StreamEncoder
+	raw bool
+}
+
+// NewStreamEncoder returns a new Encoder which encodes values directly into the stream without full buffering.
+// newEncoder is a StreamEncoder factory, for example json.NewEncoder.
+// If 'raw' is true, the debug log will print raw bytes.
+func NewStreamEncoder[E StreamEncoder](newEncoder func(w io.Writer) E, raw bool) Encoder {
+	return &streamEncoder{f: func(w io.Writer) StreamEncoder { return newEncoder(w) }, raw: raw}
+}
+
+// Encode encodes the value pointed to by v and writes it to the stream.
+// The debug log prints only the first 16KiB of the encoded value.
+func (e *streamEncoder) Encode(ctx context.Context, w io.Writer, v any) error {
+	if !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		return e.f(w).Encode(v)
+	}
+
+	slog.DebugContext(ctx, "encoder input", "value", v)
+
+	prefix := new(prefixWriter)
+
+	if err := e.f(io.MultiWriter(w, prefix)).Encode(v); err != nil {
+		return err
+	}
+
+	slog.DebugContext(ctx, "encoder output", prefix.attr(e.raw), "len", prefix.n, "truncated", prefix.truncated())
+
+	return nil
+}
+
+type streamDecoder struct {
+	f   func(r io.Reader) StreamDecoder
+	raw bool
+}
+
+// NewStreamDecoder returns a new Decoder which decodes values directly from the stream without full buffering.
+// newDecoder is a StreamDecoder factory, for example json.NewDecoder.
+// If 'raw' is true, the debug log will print raw bytes.
+func NewStreamDecoder[D StreamDecoder](newDecoder func(r io.Reader) D, raw bool) Decoder {
+	return &streamDecoder{f: func(r io.Reader) StreamDecoder { return newDecoder(r) }, raw: raw}
+}
+
+// Decode reads the next encoded value from its input and stores it in the value pointed to by v.
+// The debug log prints only the first 16KiB of the bytes read.
+func (d *streamDecoder) Decode(ctx context.Context, r io.Reader, v any) error {
+	if !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		return d.f(r).Decode(v)
+	}
+
+	prefix := new(prefixWriter)
+
+	err := d.f(io.TeeReader(r, prefix)).Decode(v)
+
+	slog.DebugContext(ctx, "decoder input", prefix.attr(d.raw), "len", prefix.n, "truncated", prefix.truncated())
+
+	if err != nil {
+		return err
+	}
+
+	slog.DebugContext(ctx, "decoder output", "value", v)
+
+	return nil
+}
+
+// NewStreamCoder returns a new Coder which does not buffer the whole encoded value in memory.
+// newEncoder and newDecoder are StreamEncoder and StreamDecoder factories,
+// for example json.NewEncoder and json.NewDecoder.
+// If 'raw' is true, the debug log will print raw bytes.
+func NewStreamCoder[E StreamEncoder, D StreamDecoder](contentType string, newEncoder func(w io.Writer) E, newDecoder func(r io.Reader) D, raw bool) Coder {
+	return &coder{t: contentType, Encoder: NewStreamEncoder(newEncoder, raw), Decoder: NewStreamDecoder(newDecoder, raw)}
+}
+
+// prefixWriter keeps the first maxDebugBytes bytes written to it and counts the rest.
+type prefixWriter struct {
+	p []byte
+	n int
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	if rest := maxDebugBytes - len(w.p); rest > 0 {
+		w.p = append(w.p, p[:min(rest, len(p))]...)
+	}
+	w.n += len(p)
+	return len(p), nil
+}
+
+func (w *prefixWriter) truncated() bool {
+	return w.n > len(w.p)
+}
+
+func (w *prefixWriter) attr(raw bool) slog.Attr {
+	if raw {
+		return slog.String("bytes", fmt.Sprintf("% x", w.p))
+	}
+	return slog.String("value", string(w.p))
+}
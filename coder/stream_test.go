@@ -0,0 +1,65 @@
+package coder_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/easy-techno-lab/proton/coder"
+)
+
+func TestStreamCoder(t *testing.T) {
+	cdr := coder.NewStreamCoder("application/json", json.NewEncoder, json.NewDecoder, false)
+
+	var tests = []struct {
+		name  string
+		debug bool
+		input *testStruct
+	}{
+		{
+			name:  "small value",
+			input: &testStruct{Field: "example"},
+		},
+		{
+			name:  "large value with debug log",
+			debug: true,
+			input: &testStruct{Field: strings.Repeat("a", 1<<16)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.debug {
+				logs := new(bytes.Buffer)
+				defer slog.SetDefault(slog.Default())
+				slog.SetDefault(slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+				defer func() {
+					equal(t, true, strings.Contains(logs.String(), "truncated=true"))
+				}()
+			}
+
+			ctx := context.Background()
+
+			buf := new(bytes.Buffer)
+			err := cdr.Encode(ctx, buf, test.input)
+			equal(t, nil, err)
+
+			output := new(testStruct)
+			err = cdr.Decode(ctx, buf, output)
+			equal(t, nil, err)
+			equal(t, test.input, output)
+		})
+	}
+}
+
+func TestStreamDecoder_Decode(t *testing.T) {
+	decoder := coder.NewStreamDecoder(json.NewDecoder, false)
+
+	err := decoder.Decode(context.Background(), strings.NewReader("{\"field\":\"example\""), new(testStruct))
+	equal(t, true, errors.Is(err, io.ErrUnexpectedEOF))
+}
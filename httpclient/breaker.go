@@ -0,0 +1,249 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultFailureWindow    = time.Minute
+	defaultOpenTimeout      = 30 * time.Second
+	defaultHalfOpenRequests = 1
+)
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	StateClosed CircuitState = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// ErrCircuitOpen is returned by the CircuitBreaker middleware without sending the request
+// when the circuit for the request key is open.
+type ErrCircuitOpen struct {
+	Key     string    // Key of the circuit.
+	RetryAt time.Time // Time when the circuit lets trial requests through.
+}
+
+func (e *ErrCircuitOpen) Error() string {
+	return fmt.Sprintf("circuit breaker is open for %q until %s", e.Key, e.RetryAt.Format(time.RFC3339))
+}
+
+// CircuitBreakerOptions represents the configuration of the CircuitBreaker middleware.
+// Zero values are replaced with defaults.
+type CircuitBreakerOptions struct {
+	FailureThreshold int                                    // Number of failures within Window that opens the circuit (5 by default).
+	Window           time.Duration                          // Period in which failures are counted (1m by default).
+	OpenTimeout      time.Duration                          // Time the circuit stays open before letting trial requests through (30s by default).
+	HalfOpenRequests int                                    // Number of successful trial requests that closes the circuit (1 by default).
+	Key              func(r *http.Request) string           // Key of the circuit for the request (the URL host by default).
+	IsFailure        func(r *http.Response, err error) bool // Reports whether the result is a failure (errors and 5xx by default).
+}
+
+// CircuitBreaker stops sending requests to an upstream that keeps failing.
+// After FailureThreshold failures within Window the circuit opens and requests fail immediately with *ErrCircuitOpen.
+// After OpenTimeout the circuit becomes half-open and lets HalfOpenRequests trial requests through:
+// if all of them succeed the circuit closes, if any of them fails the circuit opens again.
+// State changes are logged.
+func CircuitBreaker(opts *CircuitBreakerOptions) func(http.RoundTripper) http.RoundTripper {
+	o := CircuitBreakerOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.FailureThreshold <= 0 {
+		o.FailureThreshold = defaultFailureThreshold
+	}
+	if o.Window <= 0 {
+		o.Window = defaultFailureWindow
+	}
+	if o.OpenTimeout <= 0 {
+		o.OpenTimeout = defaultOpenTimeout
+	}
+	if o.HalfOpenRequests <= 0 {
+		o.HalfOpenRequests = defaultHalfOpenRequests
+	}
+	if o.Key == nil {
+		o.Key = func(r *http.Request) string { return r.URL.Host }
+	}
+	if o.IsFailure == nil {
+		o.IsFailure = isFailure
+	}
+
+	var mu sync.Mutex
+	circuits := make(map[string]*circuit)
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripper(func(r *http.Request) (*http.Response, error) {
+			ctx := r.Context()
+			key := o.Key(r)
+
+			mu.Lock()
+			c, ok := circuits[key]
+			if !ok {
+				c = &circuit{key: key, opts: &o}
+				circuits[key] = c
+			}
+			mu.Unlock()
+
+			a, err := c.allow(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			response, err := next.RoundTrip(r)
+
+			if ctx.Err() == nil || err == nil {
+				c.done(ctx, a, !o.IsFailure(response, err))
+			} else {
+				c.cancel(a)
+			}
+
+			return response, err
+		})
+	}
+}
+
+func isFailure(r *http.Response, err error) bool {
+	return err != nil || r.StatusCode >= http.StatusInternalServerError
+}
+
+// circuit is the state of the circuit breaker for one key.
+type circuit struct {
+	key  string
+	opts *CircuitBreakerOptions
+
+	mu          sync.Mutex
+	state       CircuitState
+	failures    int
+	windowStart time.Time
+	openedAt    time.Time
+	trials      int    // trial requests in flight in the half-open state
+	successes   int    // successful trial requests in the half-open state
+	generation  uint64 // incremented on every state change
+}
+
+// admission is the state of the circuit in which the request was allowed.
+type admission struct {
+	generation uint64
+	trial      bool
+}
+
+// allow reports with an error whether the request can be sent.
+func (c *circuit) allow(ctx context.Context) (admission, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == StateOpen {
+		retryAt := c.openedAt.Add(c.opts.OpenTimeout)
+		if time.Now().Before(retryAt) {
+			return admission{}, &ErrCircuitOpen{Key: c.key, RetryAt: retryAt}
+		}
+		c.setState(ctx, StateHalfOpen)
+	}
+
+	a := admission{generation: c.generation}
+
+	if c.state == StateHalfOpen {
+		if c.trials+c.successes >= c.opts.HalfOpenRequests {
+			return admission{}, &ErrCircuitOpen{Key: c.key, RetryAt: time.Now().Add(c.opts.OpenTimeout)}
+		}
+		c.trials++
+		a.trial = true
+	}
+
+	return a, nil
+}
+
+// done records the result of the request.
+// The results of the requests allowed before the last state change are ignored.
+func (c *circuit) done(ctx context.Context, a admission, success bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if a.generation != c.generation {
+		return
+	}
+
+	switch c.state {
+	case StateClosed:
+		if success {
+			return
+		}
+		now := time.Now()
+		if now.Sub(c.windowStart) > c.opts.Window {
+			c.windowStart, c.failures = now, 0
+		}
+		if c.failures++; c.failures >= c.opts.FailureThreshold {
+			c.setState(ctx, StateOpen)
+		}
+	case StateHalfOpen:
+		c.trials--
+		if !success {
+			c.setState(ctx, StateOpen)
+			return
+		}
+		if c.successes++; c.successes >= c.opts.HalfOpenRequests {
+			c.setState(ctx, StateClosed)
+		}
+	default:
+	}
+}
+
+// cancel releases the trial request slot of a request cancelled by the caller.
+func (c *circuit) cancel(a admission) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if a.trial && a.generation == c.generation {
+		c.trials--
+	}
+}
+
+func (c *circuit) setState(ctx context.Context, state CircuitState) {
+	from := c.state
+
+	c.state = state
+	c.generation++
+	c.failures, c.trials, c.successes = 0, 0, 0
+	c.windowStart = time.Now()
+	if state == StateOpen {
+		c.openedAt = c.windowStart
+	}
+
+	level := slog.LevelInfo
+	if state == StateOpen {
+		level = slog.LevelWarn
+	}
+	slog.Log(ctx, level, "circuit breaker state changed",
+		slog.String("key", c.key),
+		slog.String("from", from.String()),
+		slog.String("to", state.String()),
+	)
+}
+
+// IsCircuitOpen reports whether the error was returned because the circuit is open.
+func IsCircuitOpen(err error) bool {
+	var e *ErrCircuitOpen
+	return errors.As(err, &e)
+}
@@ -0,0 +1,180 @@
+package httpclient_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/easy-techno-lab/proton/httpclient"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var fail atomic.Bool
+	var requests atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	clt := srv.Client()
+	clt.Transport = httpclient.RoundTripperSequencer(
+		clt.Transport,
+		httpclient.CircuitBreaker(&httpclient.CircuitBreakerOptions{
+			FailureThreshold: 2,
+			OpenTimeout:      50 * time.Millisecond,
+		}),
+	)
+
+	get := func() (int, error) {
+		resp, err := clt.Get(srv.URL)
+		if err != nil {
+			return 0, err
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	fail.Store(true)
+
+	for i := 0; i < 2; i++ {
+		code, err := get()
+		equal(t, nil, err)
+		equal(t, http.StatusInternalServerError, code)
+	}
+
+	_, err := get()
+	var errOpen *httpclient.ErrCircuitOpen
+	equal(t, true, errors.As(err, &errOpen))
+	equal(t, int32(2), requests.Load())
+
+	time.Sleep(60 * time.Millisecond)
+
+	code, err := get()
+	equal(t, nil, err)
+	equal(t, http.StatusInternalServerError, code)
+
+	_, err = get()
+	equal(t, true, httpclient.IsCircuitOpen(err))
+
+	fail.Store(false)
+	time.Sleep(60 * time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		code, err = get()
+		equal(t, nil, err)
+		equal(t, http.StatusOK, code)
+	}
+	equal(t, int32(6), requests.Load())
+}
+
+func TestCircuitBreaker_InFlight(t *testing.T) {
+	slow, trial := make(chan struct{}), make(chan struct{})
+	started := make(chan struct{}, 2)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			started <- struct{}{}
+			<-slow
+		case "/trial":
+			started <- struct{}{}
+			<-trial
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	// the handlers are released if the test fails
+	defer func() {
+		for _, ch := range []chan struct{}{slow, trial} {
+			select {
+			case <-ch:
+			default:
+				close(ch)
+			}
+		}
+	}()
+
+	clt := srv.Client()
+	clt.Transport = httpclient.RoundTripperSequencer(
+		clt.Transport,
+		httpclient.CircuitBreaker(&httpclient.CircuitBreakerOptions{
+			FailureThreshold: 1,
+			OpenTimeout:      50 * time.Millisecond,
+		}),
+	)
+
+	get := func(path string) (int, error) {
+		resp, err := clt.Get(srv.URL + path)
+		if err != nil {
+			return 0, err
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	type result struct {
+		code int
+		err  error
+	}
+	results := make(chan result, 2)
+	goGet := func(path string) {
+		go func() {
+			code, err := get(path)
+			results <- result{code: code, err: err}
+		}()
+		<-started
+	}
+
+	// the request is allowed while the circuit is closed
+	goGet("/slow")
+
+	code, err := get("/fail")
+	equal(t, nil, err)
+	equal(t, http.StatusInternalServerError, code)
+
+	time.Sleep(60 * time.Millisecond)
+
+	// the only trial request of the half-open circuit
+	goGet("/trial")
+
+	// the success of the request allowed before the circuit opened does not close the circuit
+	close(slow)
+	res := <-results
+	equal(t, nil, res.err)
+	equal(t, http.StatusOK, res.code)
+
+	_, err = get("/")
+	equal(t, true, httpclient.IsCircuitOpen(err))
+
+	close(trial)
+	res = <-results
+	equal(t, http.StatusInternalServerError, res.code)
+
+	_, err = get("/")
+	equal(t, true, httpclient.IsCircuitOpen(err))
+}
+
+func TestCircuitBreaker_NilOptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	clt := srv.Client()
+	clt.Transport = httpclient.RoundTripperSequencer(clt.Transport, httpclient.CircuitBreaker(nil))
+
+	resp, err := clt.Get(srv.URL)
+	equal(t, nil, err)
+	_ = resp.Body.Close()
+	equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,222 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultMaxConcurrent = 10
+
+var ErrBulkheadFull = errors.New("client bulkhead is full")
+
+// BulkheadOptions represents the configuration of the Bulkhead middleware.
+// Zero values are replaced with defaults.
+type BulkheadOptions struct {
+	MaxConcurrent int                          // Maximum number of requests in flight per key (10 by default).
+	MaxQueue      int                          // Maximum number of requests waiting for a slot per key (unlimited by default).
+	Key           func(r *http.Request) string // Key of the bulkhead for the request (the URL host by default).
+}
+
+// Bulkhead limits the number of concurrent requests per key.
+// A request is in flight until its response body is read to the end or closed, so the body must be closed.
+// A request waits for a free slot until the request context is done, then it fails with ErrBulkheadFull.
+// It fails immediately if the expected wait, estimated from how long the slots are held, would exceed
+// the context deadline, or MaxQueue requests with the same key are already waiting.
+// The slot of an upgraded connection (101 Switching Protocols) is held until the connection is closed.
+//
+// The limit adapts to the upstream: after 429 Too Many Requests or 503 Service Unavailable it is halved,
+// successful responses restore it gradually.
+func Bulkhead(opts *BulkheadOptions) func(http.RoundTripper) http.RoundTripper {
+	o := BulkheadOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.MaxConcurrent <= 0 {
+		o.MaxConcurrent = defaultMaxConcurrent
+	}
+	if o.Key == nil {
+		o.Key = func(r *http.Request) string { return r.URL.Host }
+	}
+
+	var mu sync.Mutex
+	bulkheads := make(map[string]*bulkhead)
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripper(func(r *http.Request) (*http.Response, error) {
+			key := o.Key(r)
+
+			mu.Lock()
+			b, ok := bulkheads[key]
+			if !ok {
+				b = &bulkhead{key: key, max: float64(o.MaxConcurrent), limit: float64(o.MaxConcurrent)}
+				bulkheads[key] = b
+			}
+			mu.Unlock()
+
+			if err := b.acquire(r.Context(), o.MaxQueue); err != nil {
+				return nil, err
+			}
+
+			start := time.Now()
+			release := func() { b.release(time.Since(start)) }
+
+			response, err := next.RoundTrip(r)
+			if err != nil {
+				release()
+				return nil, err
+			}
+
+			b.adapt(response)
+
+			if conn, ok := response.Body.(io.ReadWriteCloser); ok {
+				// bodies of 101 Switching Protocols responses are io.ReadWriteCloser and must stay so,
+				// the lifetime of the connection is not added to the hold time
+				response.Body = &releaseConn{ReadWriteCloser: conn, release: func() { b.release(0) }}
+			} else if response.Body == nil || response.Body == http.NoBody {
+				release()
+			} else {
+				response.Body = &releaseBody{ReadCloser: response.Body, release: release}
+			}
+
+			return response, nil
+		})
+	}
+}
+
+// bulkhead is a semaphore of a key with a FIFO queue of waiting requests.
+type bulkhead struct {
+	key string
+
+	mu     sync.Mutex
+	max    float64
+	limit  float64 // current limit, it is lower than max after the upstream asked to slow down
+	active int
+	queue  []chan struct{}
+	hold   time.Duration // moving average of the time a slot is held
+}
+
+func (b *bulkhead) acquire(ctx context.Context, maxQueue int) error {
+	b.mu.Lock()
+
+	if b.active < int(b.limit) && len(b.queue) == 0 {
+		b.active++
+		b.mu.Unlock()
+		return nil
+	}
+
+	if maxQueue > 0 && len(b.queue) >= maxQueue {
+		b.mu.Unlock()
+		return fmt.Errorf("%w for %q: %d requests are waiting", ErrBulkheadFull, b.key, len(b.queue))
+	}
+
+	// the slots are granted in turn, so the request waits for about a hold time per limit requests ahead of it
+	delay := b.hold * time.Duration(len(b.queue)/int(b.limit)+1)
+
+	if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+		b.mu.Unlock()
+		return fmt.Errorf("%w for %q: the expected wait of %s exceeds the deadline", ErrBulkheadFull, b.key, delay)
+	}
+
+	ready := make(chan struct{})
+	b.queue = append(b.queue, ready)
+	b.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, ch := range b.queue {
+		if ch == ready {
+			b.queue = append(b.queue[:i], b.queue[i+1:]...)
+			return fmt.Errorf("%w for %q: %w", ErrBulkheadFull, b.key, ctx.Err())
+		}
+	}
+
+	// the slot has been granted while the context was being cancelled
+	b.active--
+	b.grant()
+
+	return fmt.Errorf("%w for %q: %w", ErrBulkheadFull, b.key, ctx.Err())
+}
+
+// release frees the slot held for held, zero held is not added to the average hold time.
+func (b *bulkhead) release(held time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case held <= 0:
+	case b.hold == 0:
+		b.hold = held
+	default:
+		b.hold += (held - b.hold) / 8
+	}
+
+	b.active--
+	b.grant()
+}
+
+// grant gives free slots to the waiting requests, b.mu must be held.
+func (b *bulkhead) grant() {
+	for len(b.queue) != 0 && b.active < int(b.limit) {
+		close(b.queue[0])
+		b.queue = b.queue[1:]
+		b.active++
+	}
+}
+
+// adapt halves the limit after 429 and 503 responses, and increases it by one after limit successful ones.
+func (b *bulkhead) adapt(response *http.Response) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case overloaded(response):
+		b.limit = max(1, b.limit/2)
+	case response.StatusCode < http.StatusBadRequest && b.limit < b.max:
+		b.limit = min(b.max, b.limit+1/b.limit)
+		b.grant()
+	}
+}
+
+// releaseBody releases the slot once when the body is read to the end or closed.
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.release)
+	}
+	return n, err
+}
+
+func (b *releaseBody) Close() error {
+	b.once.Do(b.release)
+	return b.ReadCloser.Close()
+}
+
+// releaseConn releases the slot once when the upgraded connection is closed.
+type releaseConn struct {
+	io.ReadWriteCloser
+	once    sync.Once
+	release func()
+}
+
+func (c *releaseConn) Close() error {
+	c.once.Do(c.release)
+	return c.ReadWriteCloser.Close()
+}
@@ -0,0 +1,201 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/easy-techno-lab/proton/httpclient"
+)
+
+func TestBulkhead(t *testing.T) {
+	release := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+		_, _ = io.WriteString(w, "body")
+	}))
+	defer srv.Close()
+
+	clt := srv.Client()
+	clt.Transport = httpclient.RoundTripperSequencer(clt.Transport, httpclient.Bulkhead(&httpclient.BulkheadOptions{
+		MaxConcurrent: 1,
+		MaxQueue:      1,
+	}))
+
+	// the slot is held until the body is closed
+	slow, err := clt.Get(srv.URL + "/slow")
+	equal(t, nil, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	queued := make(chan error, 1)
+	go func() {
+		defer wg.Done()
+		resp, err := clt.Get(srv.URL)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		queued <- err
+	}()
+
+	// wait for the request to be queued
+	time.Sleep(20 * time.Millisecond)
+
+	// the queue is full
+	_, err = clt.Get(srv.URL)
+	equal(t, true, errors.Is(err, httpclient.ErrBulkheadFull))
+
+	close(release)
+	_, _ = io.Copy(io.Discard, slow.Body)
+	_ = slow.Body.Close()
+
+	wg.Wait()
+	equal(t, nil, <-queued)
+}
+
+func TestBulkhead_Context(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "body")
+	}))
+	defer srv.Close()
+
+	clt := srv.Client()
+	clt.Transport = httpclient.RoundTripperSequencer(clt.Transport, httpclient.Bulkhead(&httpclient.BulkheadOptions{
+		MaxConcurrent: 1,
+	}))
+
+	// the slot is held until the body is closed
+	held, err := clt.Get(srv.URL)
+	equal(t, nil, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	equal(t, nil, err)
+	_, err = clt.Do(req)
+	equal(t, true, errors.Is(err, httpclient.ErrBulkheadFull))
+	equal(t, true, errors.Is(err, context.DeadlineExceeded))
+
+	_ = held.Body.Close()
+
+	resp, err := clt.Get(srv.URL)
+	equal(t, nil, err)
+	_ = resp.Body.Close()
+}
+
+func TestBulkhead_Deadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "body")
+	}))
+	defer srv.Close()
+
+	clt := srv.Client()
+	clt.Transport = httpclient.RoundTripperSequencer(clt.Transport, httpclient.Bulkhead(&httpclient.BulkheadOptions{
+		MaxConcurrent: 1,
+	}))
+
+	// the slot is held for about 200ms
+	resp, err := clt.Get(srv.URL)
+	equal(t, nil, err)
+	time.Sleep(200 * time.Millisecond)
+	_ = resp.Body.Close()
+
+	held, err := clt.Get(srv.URL)
+	equal(t, nil, err)
+	defer func() { _ = held.Body.Close() }()
+
+	// the expected wait exceeds the deadline, so the request is not queued
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	equal(t, nil, err)
+	_, err = clt.Do(req)
+	equal(t, true, errors.Is(err, httpclient.ErrBulkheadFull))
+	equal(t, false, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestBulkhead_Upgrade(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "echo" {
+			_, _ = io.WriteString(w, "body")
+			return
+		}
+
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		_, _ = buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		_ = buf.Flush()
+		_, _ = io.Copy(conn, buf)
+	}))
+	defer srv.Close()
+
+	clt := srv.Client()
+	clt.Transport = httpclient.RoundTripperSequencer(clt.Transport, httpclient.Bulkhead(&httpclient.BulkheadOptions{
+		MaxConcurrent: 1,
+	}))
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	equal(t, nil, err)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "echo")
+
+	resp, err := clt.Do(req)
+	equal(t, nil, err)
+	equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	// the body of the upgraded connection stays writable
+	conn, ok := resp.Body.(io.ReadWriter)
+	equal(t, true, ok)
+
+	_, err = io.WriteString(conn, "ping")
+	equal(t, nil, err)
+
+	b := make([]byte, 4)
+	_, err = io.ReadFull(conn, b)
+	equal(t, nil, err)
+	equal(t, "ping", string(b))
+
+	// the slot is held until the connection is closed
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	equal(t, nil, err)
+	_, err = clt.Do(req)
+	equal(t, true, errors.Is(err, httpclient.ErrBulkheadFull))
+
+	_ = resp.Body.Close()
+
+	resp, err = clt.Get(srv.URL)
+	equal(t, nil, err)
+	_ = resp.Body.Close()
+}
+
+func TestBulkhead_NilOptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	clt := srv.Client()
+	clt.Transport = httpclient.RoundTripperSequencer(clt.Transport, httpclient.Bulkhead(nil))
+
+	resp, err := clt.Get(srv.URL)
+	equal(t, nil, err)
+	_ = resp.Body.Close()
+	equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"log/slog"
 	"net/http"
 
 	"github.com/easy-techno-lab/proton/coder"
+	"github.com/easy-techno-lab/proton/problem"
 )
 
 type Client interface {
@@ -14,9 +16,16 @@ type Client interface {
 	Request(ctx context.Context, method, url string, body any, f func(*http.Request)) (*http.Response, error)
 }
 
+// ResponseDecoder is implemented by the Clients which choose the Coder by the response, see NewWithRegistry.
+type ResponseDecoder interface {
+	DecodeResponse(ctx context.Context, response *http.Response, v any) error
+}
+
 type protoClient struct {
 	coder.Coder
 	*http.Client
+
+	registry *coder.Registry
 }
 
 // New returns a new Client.
@@ -24,6 +33,12 @@ func New(coder coder.Coder, client *http.Client) Client {
 	return &protoClient{Coder: coder, Client: client}
 }
 
+// NewWithRegistry returns a new Client which encodes request bodies with the default Coder of the Registry,
+// accepts all media types of the Registry, and decodes responses with the Coder matching their Content-Type.
+func NewWithRegistry(registry *coder.Registry, client *http.Client) Client {
+	return &protoClient{Coder: registry.Default(), Client: client, registry: registry}
+}
+
 // Request sends an HTTP request based on the given method, URL, and optional body, and returns an HTTP response.
 // To add additional data to the request, use the optional function f.
 func (c *protoClient) Request(ctx context.Context, method, url string, body any, f func(*http.Request)) (*http.Response, error) {
@@ -45,9 +60,54 @@ func (c *protoClient) Request(ctx context.Context, method, url string, body any,
 		request.Header.Set(coder.ContentType, c.ContentType())
 	}
 
+	if c.registry != nil {
+		request.Header.Set("Accept", c.registry.Accept())
+	}
+
 	if f != nil {
 		f(request)
 	}
 
 	return c.Do(request)
 }
+
+// DecodeResponse reads the response body and stores it in the value pointed to by v.
+// If the Client implements ResponseDecoder, its DecodeResponse is used, otherwise the body is decoded with the Client.
+func DecodeResponse(ctx context.Context, c Client, response *http.Response, v any) error {
+	if d, ok := c.(ResponseDecoder); ok {
+		return d.DecodeResponse(ctx, response, v)
+	}
+
+	return c.Decode(ctx, response.Body, v)
+}
+
+// DecodeResponse reads the response body and stores it in the value pointed to by v.
+// If the Client was created with a Registry, the Coder is chosen by the Content-Type of the response,
+// and coder.ErrUnsupportedMediaType is returned when none matches.
+func (c *protoClient) DecodeResponse(ctx context.Context, response *http.Response, v any) error {
+	if c.registry == nil {
+		return c.Decode(ctx, response.Body, v)
+	}
+
+	cdr, err := c.registry.Lookup(response.Header.Get(coder.ContentType))
+	if err != nil {
+		return err
+	}
+
+	return cdr.Decode(ctx, response.Body, v)
+}
+
+// DecodeError returns nil if the response status code is less than 400,
+// otherwise it reads the response body and returns it as *problem.Details.
+// The returned error matches the problem sentinels with errors.Is, for example problem.ErrNotFound.
+func DecodeError(ctx context.Context, response *http.Response) error {
+	if response.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+
+	p := problem.FromResponse(response)
+
+	slog.DebugContext(ctx, "error response", "error", p)
+
+	return p
+}
@@ -3,6 +3,8 @@ package httpclient_test
 import (
 	"context"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
@@ -10,6 +12,7 @@ import (
 
 	"github.com/easy-techno-lab/proton/coder"
 	"github.com/easy-techno-lab/proton/httpclient"
+	"github.com/easy-techno-lab/proton/problem"
 )
 
 func equal(t *testing.T, exp, got any) {
@@ -154,3 +157,122 @@ func TestProtoClient_ContentType(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeResponse(t *testing.T) {
+	cdrXML := coder.NewCoder("application/xml", xml.Marshal, xml.Unmarshal, false)
+	registry := coder.NewRegistry(cdrJSON, cdrXML)
+
+	var tests = []struct {
+		name        string
+		contentType string
+		body        string
+		output      any
+		err         error
+	}{
+		{
+			name:        "JSON response",
+			contentType: "application/json",
+			body:        `{"Field":"example"}`,
+			output:      &clientTestStruct{Field: "example"},
+		},
+		{
+			name:        "XML response",
+			contentType: "application/xml; charset=utf-8",
+			body:        `<clientTestStruct><Field>example</Field></clientTestStruct>`,
+			output:      &clientTestStruct{Field: "example"},
+		},
+		{
+			name:        "unsupported media type",
+			contentType: "image/png",
+			err:         coder.ErrUnsupportedMediaType,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				equal(t, registry.Accept(), r.Header.Get("Accept"))
+				w.Header().Set(coder.ContentType, test.contentType)
+				_, _ = w.Write([]byte(test.body))
+			}))
+			defer srv.Close()
+
+			clt := httpclient.NewWithRegistry(registry, srv.Client())
+
+			ctx := context.Background()
+
+			resp, err := clt.Request(ctx, http.MethodGet, srv.URL+"/path", nil, nil)
+			equal(t, nil, err)
+
+			defer func() { _ = resp.Body.Close() }()
+
+			output := &clientTestStruct{}
+
+			err = httpclient.DecodeResponse(ctx, clt, resp, output)
+			equal(t, test.err, err)
+			if test.err == nil {
+				equal(t, test.output, output)
+			}
+		})
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	var tests = []struct {
+		name        string
+		statusCode  int
+		contentType string
+		body        string
+		err         error
+		target      error
+	}{
+		{
+			name:       "success",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:        "problem details",
+			statusCode:  http.StatusNotFound,
+			contentType: problem.ContentType,
+			body:        `{"title":"Not Found","status":404,"detail":"user not found","id":7}`,
+			err:         problem.New(http.StatusNotFound, "user not found").With("id", float64(7)),
+			target:      problem.ErrNotFound,
+		},
+		{
+			name:        "plain text",
+			statusCode:  http.StatusBadGateway,
+			contentType: "text/plain; charset=utf-8",
+			body:        "upstream is unavailable\n",
+			err:         problem.New(http.StatusBadGateway, "upstream is unavailable"),
+			target:      problem.ErrServer,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if test.contentType != "" {
+					w.Header().Set(coder.ContentType, test.contentType)
+				}
+				w.WriteHeader(test.statusCode)
+				_, _ = w.Write([]byte(test.body))
+			}))
+			defer srv.Close()
+
+			clt := httpclient.New(cdrJSON, srv.Client())
+
+			ctx := context.Background()
+
+			resp, err := clt.Request(ctx, http.MethodGet, srv.URL+"/path", nil, nil)
+			equal(t, nil, err)
+
+			defer func() { _ = resp.Body.Close() }()
+
+			err = httpclient.DecodeError(ctx, resp)
+			equal(t, test.err, err)
+			if test.target != nil {
+				equal(t, true, errors.Is(err, test.target))
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+
+	"github.com/easy-techno-lab/proton/problem"
+)
+
+// Meta is the metadata of the response returned by Do.
+type Meta struct {
+	StatusCode int
+	Header     http.Header
+}
+
+// Option configures a request made by Do.
+type Option func(*options)
+
+type options struct {
+	request  []func(*http.Request)
+	newError func() error
+}
+
+// WithRequest adds a function which modifies the request before it is sent.
+func WithRequest(f func(*http.Request)) Option {
+	return func(o *options) {
+		o.request = append(o.request, f)
+	}
+}
+
+// WithHeader sets the request header.
+func WithHeader(key, value string) Option {
+	return WithRequest(func(r *http.Request) {
+		r.Header.Set(key, value)
+	})
+}
+
+// WithQuery adds the values to the request query.
+func WithQuery(values url.Values) Option {
+	return WithRequest(func(r *http.Request) {
+		query := r.URL.Query()
+		for key, vs := range values {
+			query[key] = append(query[key], vs...)
+		}
+		r.URL.RawQuery = query.Encode()
+	})
+}
+
+// WithError sets the factory of the error the body of a non-2xx response is decoded into.
+// newError must return a pointer, for example:
+//
+//	httpclient.WithError(func() error { return new(MyError) })
+//
+// By default, the error is *problem.Details returned by DecodeError.
+func WithError(newError func() error) Option {
+	return func(o *options) {
+		o.newError = newError
+	}
+}
+
+// Do sends the request with the optional body in and decodes the body of a 2xx response into Out.
+// The body of a non-2xx response is decoded into the error, see WithError.
+// The response body is always drained and closed, its status code and headers are returned in *Meta.
+// *Meta is nil only if the request has not been sent.
+func Do[Out any](ctx context.Context, c Client, method, rawURL string, in any, opts ...Option) (Out, *Meta, error) {
+	var out Out
+
+	o := new(options)
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var f func(*http.Request)
+	if len(o.request) != 0 {
+		f = func(r *http.Request) {
+			for _, fn := range o.request {
+				fn(r)
+			}
+		}
+	}
+
+	response, err := c.Request(ctx, method, rawURL, in, f)
+	if err != nil {
+		return out, nil, err
+	}
+
+	defer drain(response.Body)
+
+	meta := &Meta{StatusCode: response.StatusCode, Header: response.Header}
+
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return out, meta, decodeError(ctx, c, response, o.newError)
+	}
+
+	if !hasBody(response) {
+		return out, meta, nil
+	}
+
+	target := any(&out)
+	if rv := reflect.ValueOf(&out).Elem(); rv.Kind() == reflect.Pointer {
+		rv.Set(reflect.New(rv.Type().Elem()))
+		target = rv.Interface()
+	}
+
+	if err = DecodeResponse(ctx, c, response, target); err != nil && !errors.Is(err, io.EOF) {
+		var zero Out
+		return zero, meta, fmt.Errorf("decode response: %w", err)
+	}
+
+	return out, meta, nil
+}
+
+// Get sends a GET request, see Do.
+func Get[Out any](ctx context.Context, c Client, rawURL string, opts ...Option) (Out, *Meta, error) {
+	return Do[Out](ctx, c, http.MethodGet, rawURL, nil, opts...)
+}
+
+// Post sends a POST request, see Do.
+func Post[Out any](ctx context.Context, c Client, rawURL string, in any, opts ...Option) (Out, *Meta, error) {
+	return Do[Out](ctx, c, http.MethodPost, rawURL, in, opts...)
+}
+
+// Put sends a PUT request, see Do.
+func Put[Out any](ctx context.Context, c Client, rawURL string, in any, opts ...Option) (Out, *Meta, error) {
+	return Do[Out](ctx, c, http.MethodPut, rawURL, in, opts...)
+}
+
+// Patch sends a PATCH request, see Do.
+func Patch[Out any](ctx context.Context, c Client, rawURL string, in any, opts ...Option) (Out, *Meta, error) {
+	return Do[Out](ctx, c, http.MethodPatch, rawURL, in, opts...)
+}
+
+// Delete sends a DELETE request, see Do.
+func Delete[Out any](ctx context.Context, c Client, rawURL string, opts ...Option) (Out, *Meta, error) {
+	return Do[Out](ctx, c, http.MethodDelete, rawURL, nil, opts...)
+}
+
+func hasBody(response *http.Response) bool {
+	return response.StatusCode != http.StatusNoContent &&
+		(response.Request == nil || response.Request.Method != http.MethodHead) &&
+		response.ContentLength != 0
+}
+
+func decodeError(ctx context.Context, c Client, response *http.Response, newError func() error) error {
+	if newError == nil {
+		if err := DecodeError(ctx, response); err != nil {
+			return err
+		}
+		return problem.New(response.StatusCode, "")
+	}
+
+	e := newError()
+	if !hasBody(response) {
+		return e
+	}
+
+	if err := DecodeResponse(ctx, c, response, e); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("decode error response with status %d: %w", response.StatusCode, err)
+	}
+
+	return e
+}
@@ -0,0 +1,117 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/easy-techno-lab/proton/coder"
+	"github.com/easy-techno-lab/proton/httpclient"
+	"github.com/easy-techno-lab/proton/problem"
+)
+
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func TestDo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Path", r.URL.Path)
+		switch r.URL.Path {
+		case "/ok":
+			w.Header().Set(coder.ContentType, "application/json")
+			_, _ = fmt.Fprintf(w, `{"Field":%q}`, r.URL.Query().Get("field")+r.Header.Get("X-Field"))
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		case "/problem":
+			w.Header().Set(coder.ContentType, problem.ContentType)
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `{"status":404,"title":"Not Found","detail":"user not found"}`)
+		case "/custom":
+			w.Header().Set(coder.ContentType, "application/json")
+			w.WriteHeader(http.StatusConflict)
+			_, _ = fmt.Fprint(w, `{"code":"conflict","message":"already exists"}`)
+		}
+	}))
+	defer srv.Close()
+
+	clt := httpclient.New(cdrJSON, srv.Client())
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		out, meta, err := httpclient.Get[*clientTestStruct](ctx, clt, srv.URL+"/ok",
+			httpclient.WithQuery(url.Values{"field": {"a"}}),
+			httpclient.WithHeader("X-Field", "b"),
+		)
+		equal(t, nil, err)
+		equal(t, &clientTestStruct{Field: "ab"}, out)
+		equal(t, http.StatusOK, meta.StatusCode)
+		equal(t, "/ok", meta.Header.Get("X-Request-Path"))
+	})
+
+	t.Run("no content", func(t *testing.T) {
+		out, meta, err := httpclient.Post[*clientTestStruct](ctx, clt, srv.URL+"/empty", &serverTestStruct{Field: 1})
+		equal(t, nil, err)
+		equal(t, (*clientTestStruct)(nil), out)
+		equal(t, http.StatusNoContent, meta.StatusCode)
+	})
+
+	t.Run("problem details", func(t *testing.T) {
+		_, meta, err := httpclient.Get[clientTestStruct](ctx, clt, srv.URL+"/problem")
+		equal(t, true, errors.Is(err, problem.ErrNotFound))
+		equal(t, http.StatusNotFound, meta.StatusCode)
+	})
+
+	t.Run("custom error type", func(t *testing.T) {
+		_, meta, err := httpclient.Delete[clientTestStruct](ctx, clt, srv.URL+"/custom",
+			httpclient.WithError(func() error { return new(apiError) }),
+		)
+		var e *apiError
+		equal(t, true, errors.As(err, &e))
+		equal(t, &apiError{Code: "conflict", Message: "already exists"}, e)
+		equal(t, http.StatusConflict, meta.StatusCode)
+	})
+}
+
+// mockClient implements only the Client interface, like the mocks outside the package.
+type mockClient struct {
+	coder.Coder
+	clt *http.Client
+}
+
+func (c *mockClient) Request(ctx context.Context, method, rawURL string, _ any, _ func(*http.Request)) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.clt.Do(request)
+}
+
+func TestDo_Client(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"Field":"example"}`))
+	}))
+	defer srv.Close()
+
+	clt := &mockClient{Coder: cdrJSON, clt: srv.Client()}
+
+	out, _, err := httpclient.Get[clientTestStruct](context.Background(), clt, srv.URL)
+	equal(t, nil, err)
+	equal(t, clientTestStruct{Field: "example"}, out)
+
+	_, _, err = httpclient.Get[clientTestStruct](context.Background(), clt, srv.URL+"/missing")
+	equal(t, true, errors.Is(err, problem.ErrNotFound))
+}
@@ -0,0 +1,86 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/easy-techno-lab/proton/metrics"
+)
+
+// Metrics records the metrics of the sent requests in the Registry:
+//
+//	http_client_requests_total — counter of requests by method, host and status.
+//	http_client_request_duration_seconds — histogram of durations until the response headers by method, host and status.
+//	http_client_response_size_bytes — histogram of read response body sizes by method, host and status.
+//	http_client_requests_in_flight — gauge of requests waiting for the response headers by host.
+//
+// The status is "error" if the request failed without a response, non-standard methods are recorded as OTHER.
+// The response size is recorded when the body is closed.
+func Metrics(reg *metrics.Registry) func(http.RoundTripper) http.RoundTripper {
+	labels := []string{"method", "host", "status"}
+
+	requests := reg.Counter("http_client_requests_total",
+		"Total number of HTTP requests sent.", labels...)
+	duration := reg.Histogram("http_client_request_duration_seconds",
+		"Duration of HTTP requests in seconds.", metrics.DefBuckets, labels...)
+	size := reg.Histogram("http_client_response_size_bytes",
+		"Size of HTTP response bodies in bytes.", metrics.ExponentialBuckets(100, 10, 7), labels...)
+	inFlight := reg.Gauge("http_client_requests_in_flight",
+		"Number of HTTP requests waiting for the response.", "host")
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripper(func(r *http.Request) (*http.Response, error) {
+			start := time.Now()
+
+			g := inFlight.With(r.URL.Host)
+			g.Inc()
+			resp, err := next.RoundTrip(r)
+			g.Dec()
+
+			status := "error"
+			if err == nil {
+				status = strconv.Itoa(resp.StatusCode)
+			}
+
+			values := []string{metrics.Method(r.Method), r.URL.Host, status}
+			requests.With(values...).Inc()
+			duration.With(values...).Observe(time.Since(start).Seconds())
+
+			if err != nil {
+				return resp, err
+			}
+
+			h := size.With(values...)
+			if resp.Body == nil || resp.Body == http.NoBody {
+				h.Observe(0)
+			} else if _, ok := resp.Body.(io.Writer); !ok {
+				// bodies of 101 Switching Protocols responses are io.ReadWriteCloser and must stay so
+				resp.Body = &countingBody{ReadCloser: resp.Body, observe: h.Observe}
+			}
+
+			return resp, nil
+		})
+	}
+}
+
+// countingBody counts the bytes read from the body and reports them once on Close.
+type countingBody struct {
+	io.ReadCloser
+	n       int64
+	once    sync.Once
+	observe func(float64)
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	b.once.Do(func() { b.observe(float64(b.n)) })
+	return b.ReadCloser.Close()
+}
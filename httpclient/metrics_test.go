@@ -0,0 +1,64 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/easy-techno-lab/proton/httpclient"
+	"github.com/easy-techno-lab/proton/metrics"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("response"))
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	equal(t, nil, err)
+
+	clt := srv.Client()
+	clt.Transport = httpclient.RoundTripperSequencer(clt.Transport, httpclient.Metrics(reg))
+
+	for _, path := range []string{"/", "/", "/missing"} {
+		resp, err := clt.Get(srv.URL + path)
+		equal(t, nil, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+
+	_, err = clt.Get("http://127.0.0.1:0/")
+	equal(t, true, err != nil)
+
+	req, err := http.NewRequest("PURGE", srv.URL, nil)
+	equal(t, nil, err)
+	resp, err := clt.Do(req)
+	equal(t, nil, err)
+	_ = resp.Body.Close()
+
+	buf := new(bytes.Buffer)
+	_, err = reg.WriteTo(buf)
+	equal(t, nil, err)
+
+	for _, line := range []string{
+		`http_client_requests_total{method="GET",host="` + u.Host + `",status="200"} 2`,
+		`http_client_requests_total{method="GET",host="` + u.Host + `",status="404"} 1`,
+		`http_client_requests_total{method="GET",host="127.0.0.1:0",status="error"} 1`,
+		`http_client_requests_total{method="OTHER",host="` + u.Host + `",status="200"} 1`,
+		`http_client_response_size_bytes_sum{method="GET",host="` + u.Host + `",status="200"} 16`,
+		`http_client_requests_in_flight{host="` + u.Host + `"} 0`,
+	} {
+		equal(t, true, strings.Contains(buf.String(), line+"\n"))
+	}
+}
@@ -0,0 +1,326 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/easy-techno-lab/proton/coder"
+	"github.com/easy-techno-lab/proton/jwt"
+)
+
+const (
+	defaultExpiryDelta       = 10 * time.Second
+	defaultAssertionLifetime = 5 * time.Minute
+	tokenRequestTimeout      = 30 * time.Second
+
+	grantClientCredentials = "client_credentials"
+	grantJWTBearer         = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+)
+
+var ErrNoAccessToken = errors.New("the token response has no access token")
+
+// Token is an OAuth2 access token.
+//
+//	AccessToken — the token sent to the upstream.
+//	TokenType — type of the token, "Bearer" if empty.
+//	ExpiresIn — lifetime of the token in seconds, the token does not expire if it is zero.
+//	Scope — space-separated scopes granted to the token.
+//	Expiry — time the token expires, it is set from ExpiresIn when the token is received.
+type Token struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresIn   int64     `json:"expires_in"`
+	Scope       string    `json:"scope"`
+	Expiry      time.Time `json:"-"`
+}
+
+// valid reports whether the token can be used for at least delta.
+func (t *Token) valid(delta time.Duration) bool {
+	return t != nil && (t.Expiry.IsZero() || time.Now().Add(delta).Before(t.Expiry))
+}
+
+func (t *Token) authorization() string {
+	if t.TokenType == "" || strings.EqualFold(t.TokenType, "Bearer") {
+		return "Bearer " + t.AccessToken
+	}
+	return t.TokenType + " " + t.AccessToken
+}
+
+// TokenError is an error response of the token endpoint, see RFC 6749 section 5.2.
+type TokenError struct {
+	Status      int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *TokenError) Error() string {
+	msg := fmt.Sprintf("token request failed: %d %s", e.Status, e.Code)
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	return msg
+}
+
+// StatusCode returns the status code of the token endpoint response.
+func (e *TokenError) StatusCode() int {
+	return e.Status
+}
+
+// OAuth2Options represents the configuration of the OAuth2 middleware.
+// Zero values are replaced with defaults.
+type OAuth2Options struct {
+	TokenURL          string                                    // URL of the token endpoint, it is required.
+	ClientID          string                                    // Client ID, it is sent with HTTP Basic authentication.
+	ClientSecret      string                                    // Client secret, it is sent with HTTP Basic authentication.
+	CredentialsInBody bool                                      // Send ClientID and ClientSecret in the request body.
+	Scopes            []string                                  // Scopes requested for the token.
+	Params            url.Values                                // Additional parameters of the token request, for example audience.
+	Assertion         func(ctx context.Context) (string, error) // JWT-bearer assertion, the client credentials grant is used if nil.
+	Coder             coder.Coder                               // Coder of the token response (JSON by default).
+	Client            *http.Client                              // Client of the token endpoint (http.DefaultClient by default).
+	ExpiryDelta       time.Duration                             // How long before the expiry the token is refreshed (10s by default).
+}
+
+// OAuth2 adds an OAuth2 access token to the Authorization header of requests.
+// The token is requested from the token endpoint with the client credentials grant, or with the JWT-bearer grant
+// (RFC 7523) if Assertion is set, and is cached until shortly before it expires. Concurrent requests wait for
+// the same token request. If the upstream answers 401 Unauthorized, the token is refreshed and the request is sent
+// once more, requests with a body are sent again only if http.Request.GetBody is set.
+func OAuth2(opts *OAuth2Options) func(http.RoundTripper) http.RoundTripper {
+	o := OAuth2Options{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.TokenURL == "" {
+		panic("httpclient: OAuth2 requires a TokenURL")
+	}
+	if o.Coder == nil {
+		o.Coder = coder.NewCoder("application/json", json.Marshal, json.Unmarshal, false)
+	}
+	if o.Client == nil {
+		o.Client = http.DefaultClient
+	}
+	if o.ExpiryDelta <= 0 {
+		o.ExpiryDelta = defaultExpiryDelta
+	}
+
+	src := &tokenSource{o: &o}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripper(func(r *http.Request) (*http.Response, error) {
+			ctx := r.Context()
+
+			token, err := src.token(ctx, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			req := r.Clone(ctx)
+			req.Header.Set("Authorization", token.authorization())
+
+			response, err := next.RoundTrip(req)
+			if err != nil || response.StatusCode != http.StatusUnauthorized {
+				return response, err
+			}
+
+			if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+				return response, nil
+			}
+
+			drain(response.Body)
+
+			if token, err = src.token(ctx, token); err != nil {
+				return nil, err
+			}
+
+			if req, err = rewind(r); err != nil {
+				return nil, err
+			}
+			req.Header.Set("Authorization", token.authorization())
+
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// tokenSource caches the token and merges concurrent token requests into one.
+type tokenSource struct {
+	o *OAuth2Options
+
+	mu      sync.Mutex
+	current *Token
+	call    *tokenCall
+}
+
+// tokenCall is a token request in progress.
+type tokenCall struct {
+	done  chan struct{}
+	token *Token
+	err   error
+}
+
+// token returns the cached token or requests a new one.
+// If rejected is not nil, the upstream rejected it, so it is not used even if it has not expired.
+func (s *tokenSource) token(ctx context.Context, rejected *Token) (*Token, error) {
+	s.mu.Lock()
+
+	if s.current != rejected && s.current.valid(s.o.ExpiryDelta) {
+		token := s.current
+		s.mu.Unlock()
+		return token, nil
+	}
+
+	c := s.call
+	if c == nil {
+		c = &tokenCall{done: make(chan struct{})}
+		s.call = c
+
+		// the request is not bound to the caller, so that its cancellation does not fail the other callers
+		go s.fetch(context.WithoutCancel(ctx), c)
+	}
+
+	s.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.token, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *tokenSource) fetch(ctx context.Context, c *tokenCall) {
+	ctx, cancel := context.WithTimeout(ctx, tokenRequestTimeout)
+	defer cancel()
+
+	c.token, c.err = s.request(ctx)
+
+	s.mu.Lock()
+	if c.err == nil {
+		s.current = c.token
+	}
+	s.call = nil
+	s.mu.Unlock()
+
+	close(c.done)
+}
+
+// request requests a new token from the token endpoint.
+func (s *tokenSource) request(ctx context.Context) (*Token, error) {
+	o := s.o
+
+	form := url.Values{}
+	for k, v := range o.Params {
+		form[k] = v
+	}
+
+	if o.Assertion != nil {
+		assertion, err := o.Assertion(ctx)
+		if err != nil {
+			return nil, err
+		}
+		form.Set("grant_type", grantJWTBearer)
+		form.Set("assertion", assertion)
+	} else {
+		form.Set("grant_type", grantClientCredentials)
+	}
+
+	if len(o.Scopes) != 0 {
+		form.Set("scope", strings.Join(o.Scopes, " "))
+	}
+
+	if o.CredentialsInBody && o.ClientID != "" {
+		form.Set("client_id", o.ClientID)
+		form.Set("client_secret", o.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(coder.ContentType, "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", o.Coder.ContentType())
+
+	if !o.CredentialsInBody && o.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(o.ClientID), url.QueryEscape(o.ClientSecret))
+	}
+
+	var response *http.Response
+	if response, err = o.Client.Do(req); err != nil {
+		return nil, err
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	if response.StatusCode != http.StatusOK {
+		tokenErr := &TokenError{Status: response.StatusCode}
+		_ = o.Coder.Decode(ctx, response.Body, tokenErr)
+		return nil, tokenErr
+	}
+
+	token := &Token{}
+	if err = o.Coder.Decode(ctx, response.Body, token); err != nil {
+		return nil, err
+	}
+
+	if token.AccessToken == "" {
+		return nil, ErrNoAccessToken
+	}
+
+	if token.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+
+	return token, nil
+}
+
+// JWTAssertion creates JWT-bearer assertions for OAuth2Options.Assertion, see RFC 7523.
+//
+//	Algorithm — algorithm the assertion is signed with.
+//	KeyID — key ID added to the assertion header, it is omitted if empty.
+//	Key — private key or secret the assertion is signed with, see jwt.Sign.
+//	Issuer — "iss" claim, usually the client ID.
+//	Subject — "sub" claim, the client ID or the user the token is requested for.
+//	Audience — "aud" claim, usually the URL of the token endpoint.
+//	Lifetime — lifetime of the assertion (5m by default).
+type JWTAssertion struct {
+	Algorithm jwt.Algorithm
+	KeyID     string
+	Key       any
+	Issuer    string
+	Subject   string
+	Audience  string
+	Lifetime  time.Duration
+}
+
+// Assertion returns a new signed assertion.
+func (a *JWTAssertion) Assertion(context.Context) (string, error) {
+	lifetime := a.Lifetime
+	if lifetime <= 0 {
+		lifetime = defaultAssertionLifetime
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	return jwt.Sign(a.Algorithm, a.KeyID, jwt.Claims{
+		"iss": a.Issuer,
+		"sub": a.Subject,
+		"aud": a.Audience,
+		"iat": now.Unix(),
+		"exp": now.Add(lifetime).Unix(),
+		"jti": hex.EncodeToString(id),
+	}, a.Key)
+}
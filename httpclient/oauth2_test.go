@@ -0,0 +1,209 @@
+package httpclient_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/easy-techno-lab/proton/httpclient"
+	"github.com/easy-techno-lab/proton/jwt"
+)
+
+// tokenServer issues tokens "token-1", "token-2" and so on, and counts the token requests.
+func tokenServer(t *testing.T, expiresIn int, check func(r *http.Request)) (*httptest.Server, *atomic.Int32) {
+	var issued atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		equal(t, nil, r.ParseForm())
+		check(r)
+
+		n := issued.Add(1)
+
+		// concurrent requests are merged while the token is being issued
+		time.Sleep(20 * time.Millisecond)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "token-" + strconv.Itoa(int(n)),
+			"token_type":   "bearer",
+			"expires_in":   expiresIn,
+		})
+	}))
+
+	return srv, &issued
+}
+
+func TestOAuth2(t *testing.T) {
+	tokens, issued := tokenServer(t, 3600, func(r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		equal(t, true, ok)
+		equal(t, "client", id)
+		equal(t, "secret", secret)
+		equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		equal(t, "read write", r.PostForm.Get("scope"))
+		equal(t, "api", r.PostForm.Get("audience"))
+	})
+	defer tokens.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		equal(t, "Bearer token-1", r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	clt := srv.Client()
+	clt.Transport = httpclient.RoundTripperSequencer(clt.Transport, httpclient.OAuth2(&httpclient.OAuth2Options{
+		TokenURL:     tokens.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Scopes:       []string{"read", "write"},
+		Params:       map[string][]string{"audience": {"api"}},
+	}))
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := clt.Get(srv.URL)
+			equal(t, nil, err)
+			_ = resp.Body.Close()
+			equal(t, http.StatusOK, resp.StatusCode)
+		}()
+	}
+	wg.Wait()
+
+	// the cached token is used
+	resp, err := clt.Get(srv.URL)
+	equal(t, nil, err)
+	_ = resp.Body.Close()
+
+	equal(t, int32(1), issued.Load())
+}
+
+func TestOAuth2_Refresh(t *testing.T) {
+	// the token expires within ExpiryDelta, so it is refreshed on every request
+	tokens, issued := tokenServer(t, 5, func(r *http.Request) {})
+	defer tokens.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	clt := srv.Client()
+	clt.Transport = httpclient.RoundTripperSequencer(clt.Transport, httpclient.OAuth2(&httpclient.OAuth2Options{
+		TokenURL: tokens.URL,
+	}))
+
+	for range 2 {
+		resp, err := clt.Get(srv.URL)
+		equal(t, nil, err)
+		_ = resp.Body.Close()
+	}
+
+	equal(t, int32(2), issued.Load())
+}
+
+func TestOAuth2_Unauthorized(t *testing.T) {
+	tokens, issued := tokenServer(t, 3600, func(r *http.Request) {})
+	defer tokens.Close()
+
+	var requests atomic.Int32
+
+	// the first token is revoked
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("Authorization") == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	clt := srv.Client()
+	clt.Transport = httpclient.RoundTripperSequencer(clt.Transport, httpclient.OAuth2(&httpclient.OAuth2Options{
+		TokenURL: tokens.URL,
+	}))
+
+	resp, err := clt.Post(srv.URL, "text/plain", http.NoBody)
+	equal(t, nil, err)
+	_ = resp.Body.Close()
+
+	equal(t, http.StatusOK, resp.StatusCode)
+	equal(t, int32(2), issued.Load())
+	equal(t, int32(2), requests.Load())
+}
+
+func TestOAuth2_JWTBearer(t *testing.T) {
+	public, key, err := ed25519.GenerateKey(rand.Reader)
+	equal(t, nil, err)
+
+	var tokens *httptest.Server
+	tokens, _ = tokenServer(t, 3600, func(r *http.Request) {
+		equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.PostForm.Get("grant_type"))
+
+		v := &jwt.Verifier{Keys: jwt.StaticKeys{{ID: "key", Key: public}}, Issuer: "client", Audience: tokens.URL}
+		claims, err := v.Verify(r.Context(), r.PostForm.Get("assertion"))
+		equal(t, nil, err)
+		equal(t, "user", claims.Subject())
+	})
+	defer tokens.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		equal(t, "Bearer token-1", r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	clt := srv.Client()
+	clt.Transport = httpclient.RoundTripperSequencer(clt.Transport, httpclient.OAuth2(&httpclient.OAuth2Options{
+		TokenURL: tokens.URL,
+		Assertion: (&httpclient.JWTAssertion{
+			Algorithm: jwt.EdDSA,
+			KeyID:     "key",
+			Key:       key,
+			Issuer:    "client",
+			Subject:   "user",
+			Audience:  tokens.URL,
+		}).Assertion,
+	}))
+
+	resp, err := clt.Get(srv.URL)
+	equal(t, nil, err)
+	_ = resp.Body.Close()
+	equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestOAuth2_TokenError(t *testing.T) {
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":"invalid_client","error_description":"unknown client"}`))
+	}))
+	defer tokens.Close()
+
+	rt := httpclient.OAuth2(&httpclient.OAuth2Options{TokenURL: tokens.URL})(http.DefaultTransport)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://upstream.test", nil)
+	equal(t, nil, err)
+
+	_, err = rt.RoundTrip(req)
+
+	var tokenErr *httpclient.TokenError
+	equal(t, true, errors.As(err, &tokenErr))
+	equal(t, http.StatusUnauthorized, tokenErr.StatusCode())
+	equal(t, "invalid_client", tokenErr.Code)
+	equal(t, "unknown client", tokenErr.Description)
+}
+
+func TestOAuth2_NilOptions(t *testing.T) {
+	defer func() {
+		equal(t, "httpclient: OAuth2 requires a TokenURL", recover())
+	}()
+	httpclient.OAuth2(nil)
+}
@@ -0,0 +1,184 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRateWindow = time.Second
+	minRateFactor     = 0.1
+)
+
+var ErrRateLimited = errors.New("client rate limit exceeded")
+
+// RateLimitOptions represents the configuration of the RateLimit middleware.
+// Zero values are replaced with defaults.
+type RateLimitOptions struct {
+	Requests int                          // Number of requests allowed per Window, it is required.
+	Window   time.Duration                // Period of the limit (1s by default).
+	Burst    int                          // Number of requests that can be sent at once (Requests by default).
+	MaxQueue int                          // Maximum number of requests waiting for the limit per key (unlimited by default).
+	Key      func(r *http.Request) string // Key of the limit for the request (the URL host by default).
+}
+
+// RateLimit limits the rate of requests per key with a token bucket.
+// A request that is over the limit waits for its turn. It fails immediately with ErrRateLimited if the wait would
+// exceed the deadline of the request context, or if MaxQueue requests with the same key are already waiting.
+//
+// The limit adapts to the upstream: after 429 Too Many Requests or 503 Service Unavailable, no requests are sent
+// until the time from the Retry-After header and the rate is halved; successful responses restore it gradually.
+func RateLimit(opts *RateLimitOptions) func(http.RoundTripper) http.RoundTripper {
+	o := RateLimitOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Requests <= 0 {
+		panic("httpclient: RateLimit requires positive Requests")
+	}
+	if o.Window <= 0 {
+		o.Window = defaultRateWindow
+	}
+	if o.Burst <= 0 {
+		o.Burst = o.Requests
+	}
+	if o.Key == nil {
+		o.Key = func(r *http.Request) string { return r.URL.Host }
+	}
+
+	var mu sync.Mutex
+	limiters := make(map[string]*limiter)
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripper(func(r *http.Request) (*http.Response, error) {
+			ctx := r.Context()
+			key := o.Key(r)
+
+			mu.Lock()
+			l, ok := limiters[key]
+			if !ok {
+				l = &limiter{
+					key:    key,
+					rate:   float64(o.Requests) / o.Window.Seconds(),
+					burst:  float64(o.Burst),
+					tokens: float64(o.Burst),
+					last:   time.Now(),
+					factor: 1,
+				}
+				limiters[key] = l
+			}
+			mu.Unlock()
+
+			if err := l.wait(ctx, o.MaxQueue); err != nil {
+				return nil, err
+			}
+
+			response, err := next.RoundTrip(r)
+			if err == nil {
+				l.adapt(ctx, response)
+			}
+
+			return response, err
+		})
+	}
+}
+
+// limiter is a token bucket of a key. Tokens may go negative: they are reserved by waiting requests.
+type limiter struct {
+	key string
+
+	mu      sync.Mutex
+	rate    float64 // tokens per second
+	burst   float64
+	tokens  float64
+	last    time.Time // time the tokens were added, it is in the future while the limiter is paused by Retry-After
+	factor  float64   // part of the rate used after the upstream asked to slow down
+	waiting int
+}
+
+// wait reserves a token and waits for it.
+func (l *limiter) wait(ctx context.Context, maxQueue int) error {
+	now := time.Now()
+
+	l.mu.Lock()
+
+	rate := l.rate * l.factor
+	if now.After(l.last) {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*rate)
+		l.last = now
+	}
+
+	// the tokens are not added while the limiter is paused, so the requests are spaced by the rate after the pause
+	l.tokens--
+	delay := l.last.Sub(now)
+	if l.tokens < 0 {
+		delay += durationOf(-l.tokens / rate)
+	}
+
+	if delay <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+
+	deadline, ok := ctx.Deadline()
+	if (ok && now.Add(delay).After(deadline)) || (maxQueue > 0 && l.waiting >= maxQueue) {
+		l.tokens++
+		l.mu.Unlock()
+		return fmt.Errorf("%w for %q: retry in %s", ErrRateLimited, l.key, delay)
+	}
+
+	l.waiting++
+	l.mu.Unlock()
+
+	err := sleep(ctx, delay)
+
+	l.mu.Lock()
+	l.waiting--
+	if err != nil {
+		l.tokens++
+	}
+	l.mu.Unlock()
+
+	return err
+}
+
+// adapt slows down after 429 and 503 responses, and speeds up after successful ones.
+func (l *limiter) adapt(ctx context.Context, response *http.Response) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !overloaded(response) {
+		if response.StatusCode < http.StatusBadRequest && l.factor < 1 {
+			l.factor = min(1, l.factor+minRateFactor/2)
+		}
+		return
+	}
+
+	l.factor = max(minRateFactor, l.factor/2)
+
+	var pausedUntil time.Time
+	if d, ok := retryAfter(response); ok {
+		if pausedUntil = time.Now().Add(d); pausedUntil.After(l.last) {
+			// the bucket is empty after the pause, the tokens reserved by waiting requests stay reserved
+			l.tokens = min(l.tokens, 0)
+			l.last = pausedUntil
+		}
+	}
+
+	slog.WarnContext(ctx, "upstream asked to slow down",
+		"key", l.key, "status", response.StatusCode, "rate_factor", l.factor, "paused_until", pausedUntil)
+}
+
+// overloaded reports whether the upstream asked to slow down.
+func overloaded(response *http.Response) bool {
+	return response.StatusCode == http.StatusTooManyRequests || response.StatusCode == http.StatusServiceUnavailable
+}
+
+func durationOf(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,154 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/easy-techno-lab/proton/httpclient"
+)
+
+func TestRateLimit(t *testing.T) {
+	var requests atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer srv.Close()
+
+	clt := srv.Client()
+	clt.Transport = httpclient.RoundTripperSequencer(clt.Transport, httpclient.RateLimit(&httpclient.RateLimitOptions{
+		Requests: 10,
+		Window:   time.Second,
+		Burst:    2,
+		MaxQueue: 1,
+	}))
+
+	get := func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		equal(t, nil, err)
+		resp, err := clt.Do(req)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		return err
+	}
+
+	// the burst is sent at once
+	start := time.Now()
+	equal(t, nil, get(context.Background()))
+	equal(t, nil, get(context.Background()))
+	equal(t, true, time.Since(start) < 50*time.Millisecond)
+
+	// the next request does not fit into the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := get(ctx)
+	equal(t, true, errors.Is(err, httpclient.ErrRateLimited))
+	equal(t, int32(2), requests.Load())
+
+	// without a deadline the request waits for its turn
+	start = time.Now()
+	equal(t, nil, get(context.Background()))
+	equal(t, true, time.Since(start) >= 50*time.Millisecond)
+	equal(t, int32(3), requests.Load())
+}
+
+func TestRateLimit_RetryAfter(t *testing.T) {
+	var requests atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	clt := srv.Client()
+	clt.Transport = httpclient.RoundTripperSequencer(clt.Transport, httpclient.RateLimit(&httpclient.RateLimitOptions{
+		Requests: 100,
+	}))
+
+	resp, err := clt.Get(srv.URL)
+	equal(t, nil, err)
+	_ = resp.Body.Close()
+	equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	// the upstream asked to wait for a second
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	equal(t, nil, err)
+	_, err = clt.Do(req)
+	equal(t, true, errors.Is(err, httpclient.ErrRateLimited))
+	equal(t, int32(1), requests.Load())
+}
+
+func TestRateLimit_AfterPause(t *testing.T) {
+	var requests atomic.Int32
+
+	// the arrival times are checked in the test goroutine
+	var mu sync.Mutex
+	var arrivals []time.Time
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		mu.Lock()
+		arrivals = append(arrivals, time.Now())
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	clt := srv.Client()
+	clt.Transport = httpclient.RoundTripperSequencer(clt.Transport, httpclient.RateLimit(&httpclient.RateLimitOptions{
+		Requests: 10,
+	}))
+
+	resp, err := clt.Get(srv.URL)
+	equal(t, nil, err)
+	_ = resp.Body.Close()
+	equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	// the requests queued during the pause are not sent at once when it ends
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if resp, err := clt.Get(srv.URL); err == nil {
+				_ = resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	equal(t, 3, len(arrivals))
+	sort.Slice(arrivals, func(i, j int) bool { return arrivals[i].Before(arrivals[j]) })
+
+	// the rate is halved after 429, so the requests are spaced by about 200ms
+	for i := 1; i < len(arrivals); i++ {
+		equal(t, true, arrivals[i].Sub(arrivals[i-1]) >= 150*time.Millisecond)
+	}
+}
+
+func TestRateLimit_NilOptions(t *testing.T) {
+	defer func() {
+		equal(t, "httpclient: RateLimit requires positive Requests", recover())
+	}()
+	httpclient.RateLimit(nil)
+}
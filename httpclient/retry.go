@@ -0,0 +1,213 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMaxAttempts = 3
+	defaultBaseDelay   = 100 * time.Millisecond
+	defaultMaxDelay    = 10 * time.Second
+
+	maxDrain = 1 << 12 // 4KiB
+)
+
+var defaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryPolicy represents the configuration of the Retry middleware.
+// Zero values are replaced with defaults.
+type RetryPolicy struct {
+	MaxAttempts          int           // Maximum number of attempts including the first one (3 by default).
+	StatusCodes          []int         // Response status codes to retry (429, 502, 503, 504 by default).
+	NetworkErrors        bool          // Retry requests that failed with a network error.
+	BaseDelay            time.Duration // Delay before the first retry, doubled on every next one (100ms by default).
+	MaxDelay             time.Duration // Maximum delay between attempts (10s by default).
+	IdempotencyKeyHeader string        // Header that allows retrying non-idempotent methods ("Idempotency-Key" by default).
+}
+
+// Retry retries requests according to the policy using exponential backoff with jitter.
+// The Retry-After response header takes precedence over the backoff delay,
+// if it exceeds MaxDelay, the response is returned without retrying.
+// Non-idempotent methods are retried only when the request has the idempotency key header.
+// Requests with a body are retried only if http.Request.GetBody is set.
+// Retrying stops when the request context is done or the next attempt would exceed its deadline.
+func Retry(policy *RetryPolicy) func(http.RoundTripper) http.RoundTripper {
+	p := RetryPolicy{}
+	if policy != nil {
+		p = *policy
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaultMaxAttempts
+	}
+	if p.StatusCodes == nil {
+		p.StatusCodes = defaultRetryStatusCodes
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = defaultBaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = defaultMaxDelay
+	}
+	if p.IdempotencyKeyHeader == "" {
+		p.IdempotencyKeyHeader = "Idempotency-Key"
+	}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripper(func(r *http.Request) (*http.Response, error) {
+			if !p.retryable(r) {
+				return next.RoundTrip(r)
+			}
+
+			ctx := r.Context()
+
+			for attempt := 1; ; attempt++ {
+				req := r
+				if attempt > 1 {
+					var err error
+					if req, err = rewind(r); err != nil {
+						return nil, err
+					}
+				}
+
+				response, err := next.RoundTrip(req)
+
+				if attempt == p.MaxAttempts || !p.retry(ctx, response, err) {
+					return response, err
+				}
+
+				delay := p.delay(attempt, response)
+
+				if delay > p.MaxDelay {
+					return response, err
+				}
+
+				if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+					return response, err
+				}
+
+				if response != nil {
+					drain(response.Body)
+				}
+
+				slog.DebugContext(ctx, "retry request",
+					slog.Group("request",
+						slog.String("method", r.Method),
+						slog.String("url", r.URL.String()),
+					),
+					slog.Int("attempt", attempt),
+					slog.String("delay", delay.String()),
+				)
+
+				if err = sleep(ctx, delay); err != nil {
+					return nil, err
+				}
+			}
+		})
+	}
+}
+
+// retryable reports whether the request can be safely sent again.
+func (p *RetryPolicy) retryable(r *http.Request) bool {
+	if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+		return false
+	}
+
+	switch r.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return r.Header.Get(p.IdempotencyKeyHeader) != ""
+	}
+}
+
+// retry reports whether the result of the attempt must be retried.
+func (p *RetryPolicy) retry(ctx context.Context, response *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	if err != nil {
+		return p.NetworkErrors
+	}
+
+	return slices.Contains(p.StatusCodes, response.StatusCode)
+}
+
+// delay returns the delay before the next attempt, only the Retry-After delay can exceed MaxDelay.
+func (p *RetryPolicy) delay(attempt int, response *http.Response) time.Duration {
+	if response != nil {
+		if d, ok := retryAfter(response); ok {
+			return d
+		}
+	}
+
+	d := p.MaxDelay
+	if shift := attempt - 1; shift < 32 {
+		d = min(p.BaseDelay<<shift, p.MaxDelay)
+	}
+
+	// equal jitter: half of the delay is fixed, the other half is random
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryAfter parses the Retry-After response header.
+func retryAfter(response *http.Response) (time.Duration, bool) {
+	v := response.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+
+	return 0, false
+}
+
+// rewind returns a copy of the request with a fresh body.
+func rewind(r *http.Request) (*http.Request, error) {
+	req := r.Clone(r.Context())
+	if r.GetBody != nil && r.Body != nil && r.Body != http.NoBody {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = body
+	}
+	return req, nil
+}
+
+// drain reads a small part of the body so that the connection can be reused, and closes it.
+func drain(body io.ReadCloser) {
+	_, _ = io.CopyN(io.Discard, body, maxDrain)
+	_ = body.Close()
+}
+
+// sleep waits for the duration or until the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,134 @@
+package httpclient_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/easy-techno-lab/proton/httpclient"
+)
+
+func TestRetry(t *testing.T) {
+	var tests = []struct {
+		name       string
+		method     string
+		header     http.Header
+		failures   int32
+		retryAfter string
+		timeout    time.Duration
+		statusCode int
+		attempts   int32
+	}{
+		{
+			name:       "retry until success",
+			method:     http.MethodGet,
+			failures:   2,
+			statusCode: http.StatusOK,
+			attempts:   3,
+		},
+		{
+			name:       "max attempts exceeded",
+			method:     http.MethodGet,
+			failures:   5,
+			statusCode: http.StatusServiceUnavailable,
+			attempts:   3,
+		},
+		{
+			name:       "non-idempotent method is not retried",
+			method:     http.MethodPost,
+			failures:   1,
+			statusCode: http.StatusServiceUnavailable,
+			attempts:   1,
+		},
+		{
+			name:       "non-idempotent method with idempotency key",
+			method:     http.MethodPost,
+			header:     http.Header{"Idempotency-Key": {"key"}},
+			failures:   1,
+			statusCode: http.StatusOK,
+			attempts:   2,
+		},
+		{
+			name:       "retry-after exceeds the deadline",
+			method:     http.MethodGet,
+			failures:   1,
+			retryAfter: "10",
+			timeout:    time.Second,
+			statusCode: http.StatusServiceUnavailable,
+			attempts:   1,
+		},
+		{
+			name:       "retry-after exceeds the max delay",
+			method:     http.MethodGet,
+			failures:   1,
+			retryAfter: "60",
+			statusCode: http.StatusServiceUnavailable,
+			attempts:   1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var attempts atomic.Int32
+
+			// the bodies are checked in the test goroutine
+			var mu sync.Mutex
+			var bodies []string
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				bodies = append(bodies, string(body))
+				mu.Unlock()
+
+				if attempts.Add(1) <= test.failures {
+					if test.retryAfter != "" {
+						w.Header().Set("Retry-After", test.retryAfter)
+					}
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer srv.Close()
+
+			clt := srv.Client()
+			clt.Transport = httpclient.RoundTripperSequencer(
+				clt.Transport,
+				httpclient.Retry(&httpclient.RetryPolicy{BaseDelay: time.Millisecond}),
+			)
+
+			ctx := context.Background()
+			if test.timeout != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, test.timeout)
+				defer cancel()
+			}
+
+			req, err := http.NewRequestWithContext(ctx, test.method, srv.URL, strings.NewReader("body"))
+			equal(t, nil, err)
+			for key, values := range test.header {
+				req.Header[key] = values
+			}
+
+			resp, err := clt.Do(req)
+			equal(t, nil, err)
+			_ = resp.Body.Close()
+
+			equal(t, test.statusCode, resp.StatusCode)
+			equal(t, test.attempts, attempts.Load())
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, body := range bodies {
+				equal(t, "body", body)
+			}
+		})
+	}
+}
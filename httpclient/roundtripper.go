@@ -1,22 +1,17 @@
 package httpclient
 
 import (
-	"context"
+	"io"
 	"log/slog"
 	"net/http"
 	"runtime/debug"
+	"sync"
 	"time"
 
+	"github.com/easy-techno-lab/proton/trace"
 	"github.com/easy-techno-lab/proton/utils/log"
-	"github.com/easy-techno-lab/proton/utils/sgen"
 )
 
-func init() {
-	id.Configure("", sgen.UpLetters.Append(sgen.LowLetters, sgen.Nums), 12)
-}
-
-var id sgen.RandomString
-
 // The RoundTripper type is an adapter to allow the use of ordinary functions as HTTP round trippers.
 // If f is a function with the appropriate signature, Func(f) is a RoundTripper that calls f.
 type RoundTripper func(*http.Request) (*http.Response, error)
@@ -34,18 +29,41 @@ func RoundTripperSequencer(baseRoundTripper http.RoundTripper, rts ...func(http.
 	return baseRoundTripper
 }
 
-// Tracer adds trace ID to the request context.
+// Tracer starts a client span for the request and propagates W3C Trace Context to the upstream.
+// The span is a child of the span from the request context, or the root of a new trace if the context has none.
+// The span is added to the request context and sent in the traceparent and tracestate headers.
 func Tracer(next http.RoundTripper) http.RoundTripper {
 	return RoundTripper(func(r *http.Request) (*http.Response, error) {
-		ctx := context.WithValue(r.Context(), log.TraceCtxKey, id.Generate())
-		return next.RoundTrip(r.Clone(ctx))
+		ctx, span := trace.Start(r.Context(), r.Method, trace.WithKind(trace.KindClient), trace.WithAttributes(
+			slog.String("http.request.method", r.Method),
+			slog.String("server.address", r.URL.Host),
+			slog.String("url.full", r.URL.Redacted()),
+		))
+		defer span.End()
+
+		req := r.Clone(ctx)
+		trace.Inject(span.SpanContext(), req.Header)
+
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			span.RecordError(err)
+			return resp, err
+		}
+
+		span.SetAttributes(slog.Int("http.response.status_code", resp.StatusCode))
+		if resp.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(trace.StatusError, http.StatusText(resp.StatusCode))
+		}
+
+		return resp, nil
 	})
 }
 
 // Timer measures the time taken by http.RoundTripper.
+// If the request context has no span, Timer starts a client span like Tracer.
 func Timer(level slog.Level) func(http.RoundTripper) http.RoundTripper {
 	return func(next http.RoundTripper) http.RoundTripper {
-		return RoundTripper(func(r *http.Request) (*http.Response, error) {
+		timed := RoundTripper(func(r *http.Request) (*http.Response, error) {
 			ctx := r.Context()
 			if slog.Default().Enabled(ctx, level) {
 				defer func(start time.Time) {
@@ -59,6 +77,14 @@ func Timer(level slog.Level) func(http.RoundTripper) http.RoundTripper {
 			}
 			return next.RoundTrip(r)
 		})
+		traced := Tracer(timed)
+
+		return RoundTripper(func(r *http.Request) (*http.Response, error) {
+			if trace.SpanFromContext(r.Context()) == nil {
+				return traced.RoundTrip(r)
+			}
+			return timed.RoundTrip(r)
+		})
 	}
 }
 
@@ -76,20 +102,45 @@ func PanicCatcher(next http.RoundTripper) http.RoundTripper {
 	})
 }
 
-// DumpHttp dumps the HTTP request and response, and prints out with logFunc.
+// DumpHttp dumps the HTTP request and response with the default log.Dumper, and prints out.
 func DumpHttp(level slog.Level) func(http.RoundTripper) http.RoundTripper {
+	return DumpHttpWith(level, nil)
+}
+
+// DumpHttpWith dumps the HTTP request and response with the log.Dumper, and prints out.
+// The request is printed when the response headers are received, the response when its body is read or closed.
+// The bodies are captured while they stream. If the dumper is nil, the default one is used.
+func DumpHttpWith(level slog.Level, dumper *log.Dumper) func(http.RoundTripper) http.RoundTripper {
+	if dumper == nil {
+		dumper = new(log.Dumper)
+	}
 	return func(next http.RoundTripper) http.RoundTripper {
 		return RoundTripper(func(r *http.Request) (*http.Response, error) {
 			ctx := r.Context()
 			if slog.Default().Enabled(ctx, level) {
-				log.DumpHttpRequest(ctx, r, level)
+				requestBody := dumper.Capture()
+				if r.Body != nil && r.Body != http.NoBody {
+					r = r.Clone(ctx)
+					r.Body = requestBody.Tee(r.Body)
+				}
 
 				response, err := next.RoundTrip(r)
+
+				dumper.Request(ctx, r, requestBody, level)
+
 				if err != nil {
 					return nil, err
 				}
 
-				log.DumpHttpResponse(ctx, response, level)
+				responseBody := dumper.Capture()
+				dump := func() { dumper.Response(ctx, response, responseBody, level) }
+
+				if _, ok := response.Body.(io.Writer); ok || response.Body == nil || response.Body == http.NoBody {
+					// bodies of 101 Switching Protocols responses are io.ReadWriteCloser and must stay so
+					dump()
+				} else {
+					response.Body = &dumpBody{ReadCloser: responseBody.Tee(response.Body), dump: dump}
+				}
 
 				return response, nil
 			}
@@ -97,3 +148,23 @@ func DumpHttp(level slog.Level) func(http.RoundTripper) http.RoundTripper {
 		})
 	}
 }
+
+// dumpBody calls dump once when the body is read to the end or closed.
+type dumpBody struct {
+	io.ReadCloser
+	once sync.Once
+	dump func()
+}
+
+func (b *dumpBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.dump)
+	}
+	return n, err
+}
+
+func (b *dumpBody) Close() error {
+	b.once.Do(b.dump)
+	return b.ReadCloser.Close()
+}
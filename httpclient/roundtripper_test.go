@@ -0,0 +1,180 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/easy-techno-lab/proton/httpclient"
+	"github.com/easy-techno-lab/proton/trace"
+	"github.com/easy-techno-lab/proton/utils/log"
+)
+
+func TestTracer(t *testing.T) {
+	parent := trace.NewSpanContext()
+	parent.State = "vendor=value"
+
+	var tests = []struct {
+		name   string
+		ctx    context.Context
+		parent *trace.SpanContext
+	}{
+		{
+			name:   "child span",
+			ctx:    trace.ContextWithSpanContext(context.Background(), parent),
+			parent: &parent,
+		},
+		{
+			name: "new trace",
+			ctx:  context.Background(),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sc trace.SpanContext
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var ok bool
+				sc, ok = trace.Extract(r.Header)
+				equal(t, true, ok)
+			}))
+			defer srv.Close()
+
+			clt := srv.Client()
+			clt.Transport = httpclient.RoundTripperSequencer(clt.Transport, httpclient.Tracer)
+
+			req, err := http.NewRequestWithContext(test.ctx, http.MethodGet, srv.URL, nil)
+			equal(t, nil, err)
+
+			resp, err := clt.Do(req)
+			equal(t, nil, err)
+			_ = resp.Body.Close()
+
+			equal(t, true, sc.IsValid())
+			if test.parent != nil {
+				equal(t, test.parent.TraceID, sc.TraceID)
+				equal(t, false, test.parent.SpanID == sc.SpanID)
+				equal(t, test.parent.State, sc.State)
+			}
+		})
+	}
+}
+
+func TestTracer_Span(t *testing.T) {
+	exp := new(trace.InMemoryExporter)
+	trace.SetExporter(exp)
+	defer trace.SetExporter(nil)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	clt := srv.Client()
+	clt.Transport = httpclient.RoundTripperSequencer(clt.Transport, httpclient.Tracer)
+
+	ctx, parent := trace.Start(context.Background(), "parent")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	equal(t, nil, err)
+
+	resp, err := clt.Do(req)
+	equal(t, nil, err)
+	_ = resp.Body.Close()
+
+	spans := exp.Spans()
+	equal(t, 1, len(spans))
+
+	span := spans[0]
+	equal(t, trace.KindClient, span.Kind)
+	equal(t, parent.SpanContext().TraceID, span.SpanContext.TraceID)
+	equal(t, parent.SpanContext().SpanID, span.Parent)
+	equal(t, trace.StatusError, span.Status)
+	equal(t, slog.Int("http.response.status_code", http.StatusServiceUnavailable), span.Attributes[len(span.Attributes)-1])
+}
+
+func TestTimer_Span(t *testing.T) {
+	exp := new(trace.InMemoryExporter)
+	trace.SetExporter(exp)
+	defer trace.SetExporter(nil)
+
+	var traceparents []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents = append(traceparents, r.Header.Get(trace.TraceparentHeader))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		rts  []func(http.RoundTripper) http.RoundTripper
+	}{
+		{name: "without tracer", rts: []func(http.RoundTripper) http.RoundTripper{httpclient.Timer(slog.LevelDebug)}},
+		{name: "with tracer", rts: []func(http.RoundTripper) http.RoundTripper{httpclient.Timer(slog.LevelDebug), httpclient.Tracer}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exp.Reset()
+			traceparents = nil
+
+			clt := srv.Client()
+			clt.Transport = httpclient.RoundTripperSequencer(clt.Transport, test.rts...)
+
+			resp, err := clt.Get(srv.URL)
+			equal(t, nil, err)
+			_ = resp.Body.Close()
+
+			// the only client span is the one sent upstream
+			spans := exp.Spans()
+			equal(t, 1, len(spans))
+			equal(t, trace.KindClient, spans[0].Kind)
+			equal(t, []string{spans[0].SpanContext.Traceparent()}, traceparents)
+		})
+	}
+}
+
+func TestDumpHttp(t *testing.T) {
+	buf := new(bytes.Buffer)
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(buf, nil)))
+	defer slog.SetDefault(prev)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"echo":%q,"token":"secret"}`, b)
+	}))
+	defer srv.Close()
+
+	clt := srv.Client()
+	clt.Transport = httpclient.RoundTripperSequencer(clt.Transport,
+		httpclient.DumpHttpWith(slog.LevelInfo, &log.Dumper{RedactFields: []string{"token"}}))
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("request body"))
+	equal(t, nil, err)
+	req.Header.Set("Authorization", "Bearer secret")
+
+	resp, err := clt.Do(req)
+	equal(t, nil, err)
+
+	// the response is dumped when its body is read
+	equal(t, false, strings.Contains(buf.String(), "HTTP RESPONSE"))
+
+	b, err := io.ReadAll(resp.Body)
+	equal(t, nil, err)
+	_ = resp.Body.Close()
+	equal(t, `{"echo":"request body","token":"secret"}`, string(b))
+
+	equal(t, true, strings.Contains(buf.String(), "HTTP REQUEST"))
+	equal(t, true, strings.Contains(buf.String(), `request body`))
+	equal(t, true, strings.Contains(buf.String(), `Authorization: [REDACTED]`))
+	equal(t, true, strings.Contains(buf.String(), `\"token\":\"[REDACTED]\"`))
+	equal(t, false, strings.Contains(buf.String(), "secret"))
+}
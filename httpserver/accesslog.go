@@ -0,0 +1,182 @@
+package httpserver
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/easy-techno-lab/proton/trace"
+	"github.com/easy-techno-lab/proton/utils/log"
+)
+
+// AccessLogFormat is the format of the access log records.
+type AccessLogFormat int
+
+const (
+	AccessLogJSON     AccessLogFormat = iota // slog record with the request attributes, JSON with slog.JSONHandler
+	AccessLogCommon                          // Apache Common Log Format
+	AccessLogCombined                        // Apache Combined Log Format
+)
+
+// AccessLogOptions represents the configuration of the AccessLog middleware.
+// Zero values are replaced with defaults.
+type AccessLogOptions struct {
+	Format         AccessLogFormat // Format of the records (AccessLogJSON by default).
+	Logger         *slog.Logger    // Logger of AccessLogJSON records (slog.Default() by default).
+	Level          slog.Level      // Level of AccessLogJSON records (slog.LevelInfo by default).
+	Output         io.Writer       // Output of AccessLogCommon and AccessLogCombined records (os.Stdout by default).
+	TrustedProxies TrustedProxies  // Proxies whose forwarding headers are used to get the remote address.
+	SampleRate     float64         // Fraction of requests logged in (0, 1], server errors are always logged (1 by default).
+	ExcludeRoutes  []string        // Route patterns or paths which are not logged, for example health checks.
+}
+
+// AccessLog writes one record per request with the remote address, method, route pattern, status code,
+// response size, duration, user agent, referer and trace ID.
+func AccessLog(opts *AccessLogOptions) func(http.Handler) http.Handler {
+	o := AccessLogOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Output == nil {
+		o.Output = os.Stdout
+	}
+	if o.SampleRate <= 0 || o.SampleRate > 1 {
+		o.SampleRate = 1
+	}
+
+	exclude := make(map[string]struct{}, len(o.ExcludeRoutes))
+	for _, route := range o.ExcludeRoutes {
+		exclude[route] = struct{}{}
+	}
+
+	var mu sync.Mutex
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			r, info := withRouteInfo(r)
+			rw := NewResponseWriter(w)
+
+			next.ServeHTTP(rw, r)
+
+			if excluded(exclude, info.pattern, r.URL.Path) {
+				return
+			}
+			if rw.Status() < http.StatusInternalServerError && o.SampleRate < 1 && rand.Float64() >= o.SampleRate {
+				return
+			}
+
+			e := &accessEntry{
+				start:    start,
+				duration: time.Since(start),
+				remote:   o.TrustedProxies.ClientIP(r),
+				r:        r,
+				route:    info.pattern,
+				status:   rw.Status(),
+				bytes:    rw.BytesWritten(),
+			}
+
+			if o.Format == AccessLogJSON {
+				logger := o.Logger
+				if logger == nil {
+					logger = slog.Default()
+				}
+				logger.LogAttrs(r.Context(), o.Level, "access", e.attrs(logger.Handler())...)
+				return
+			}
+
+			line := e.common(o.Format == AccessLogCombined)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if _, err := io.WriteString(o.Output, line); err != nil {
+				slog.ErrorContext(r.Context(), "write access log", "error", err)
+			}
+		})
+	}
+}
+
+func excluded(exclude map[string]struct{}, pattern, path string) bool {
+	if len(exclude) == 0 {
+		return false
+	}
+	for _, key := range []string{pattern, metricRoute(pattern), path} {
+		if _, ok := exclude[key]; ok && key != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// accessEntry is an access log record.
+type accessEntry struct {
+	start    time.Time
+	duration time.Duration
+	remote   string
+	r        *http.Request
+	route    string
+	status   int
+	bytes    int64
+}
+
+func (e *accessEntry) attrs(h slog.Handler) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("remote_addr", e.remote),
+		slog.String("method", e.r.Method),
+		slog.String("route", metricRoute(e.route)),
+		slog.String("uri", e.r.RequestURI),
+		slog.String("proto", e.r.Proto),
+		slog.Int("status", e.status),
+		slog.Int64("bytes", e.bytes),
+		slog.Duration("duration", e.duration),
+		slog.String("user_agent", e.r.UserAgent()),
+		slog.String("referer", e.r.Referer()),
+	}
+
+	// log.TraceHandler adds the trace_id from the context, so it is added here only without the handler
+	if sc, ok := trace.SpanContextFromContext(e.r.Context()); ok && sc.IsValid() {
+		if _, ok = h.(log.TraceHandler); !ok {
+			attrs = append(attrs, slog.String("trace_id", sc.TraceID.String()))
+		}
+	}
+
+	return attrs
+}
+
+// common returns the record in the Apache Common or Combined Log Format.
+func (e *accessEntry) common(combined bool) string {
+	user := "-"
+	if u, _, ok := e.r.BasicAuth(); ok && u != "" {
+		user = u
+	}
+
+	size := "-"
+	if e.bytes > 0 {
+		size = strconv.FormatInt(e.bytes, 10)
+	}
+
+	line := fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s",
+		e.remote, user, e.start.Format("02/Jan/2006:15:04:05 -0700"),
+		e.r.Method, e.r.RequestURI, e.r.Proto, e.status, size)
+
+	if combined {
+		line += fmt.Sprintf(" %q %q", valueOrDash(e.r.Referer()), valueOrDash(e.r.UserAgent()))
+	}
+
+	return line + "\n"
+}
+
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,168 @@
+package httpserver_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/easy-techno-lab/proton/httpserver"
+	"github.com/easy-techno-lab/proton/trace"
+)
+
+func TestTrustedProxies_ClientIP(t *testing.T) {
+	proxies, err := httpserver.ParseTrustedProxies("10.0.0.0/8", "192.168.1.1")
+	equal(t, nil, err)
+
+	_, err = httpserver.ParseTrustedProxies("invalid")
+	equal(t, true, err != nil)
+
+	var tests = []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		exp        string
+	}{
+		{
+			name:       "untrusted remote address",
+			remoteAddr: "203.0.113.1:1234",
+			forwarded:  "198.51.100.1",
+			exp:        "203.0.113.1",
+		},
+		{
+			name:       "trusted proxy",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  "198.51.100.1",
+			exp:        "198.51.100.1",
+		},
+		{
+			name:       "chain of trusted proxies",
+			remoteAddr: "192.168.1.1:1234",
+			forwarded:  "1.2.3.4, 198.51.100.1, 10.0.0.2",
+			exp:        "198.51.100.1",
+		},
+		{
+			name:       "all proxies are trusted",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  "10.0.0.3, 10.0.0.2",
+			exp:        "10.0.0.3",
+		},
+		{
+			name:       "real IP is not trusted",
+			remoteAddr: "10.0.0.1:1234",
+			realIP:     "198.51.100.2",
+			exp:        "10.0.0.1",
+		},
+		{
+			name:       "invalid entry",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  "198.51.100.1, unknown, 10.0.0.2",
+			realIP:     "198.51.100.2",
+			exp:        "10.0.0.2",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = test.remoteAddr
+			if test.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", test.forwarded)
+			}
+			if test.realIP != "" {
+				r.Header.Set("X-Real-IP", test.realIP)
+			}
+			equal(t, test.exp, proxies.ClientIP(r))
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	newHandler := func(opts *httpserver.AccessLogOptions) http.Handler {
+		router := httpserver.NewRouter()
+		router.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("user"))
+		})
+		router.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {})
+		router.HandleFunc("GET /fail", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+		return httpserver.MiddlewareSequencer(router, httpserver.AccessLog(opts), httpserver.Tracer)
+	}
+
+	newRequest := func(path string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Forwarded-For", "198.51.100.1")
+		r.Header.Set("User-Agent", "test")
+		r.Header.Set("Referer", "http://example.com/")
+		return r
+	}
+
+	proxies, _ := httpserver.ParseTrustedProxies("10.0.0.0/8")
+
+	t.Run("combined", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		handler := newHandler(&httpserver.AccessLogOptions{
+			Format:         httpserver.AccessLogCombined,
+			Output:         buf,
+			TrustedProxies: proxies,
+			ExcludeRoutes:  []string{"/healthz"},
+		})
+
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest("/users/1"))
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest("/healthz"))
+
+		exp := regexp.MustCompile(`^198\.51\.100\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}] ` +
+			`"GET /users/1 HTTP/1\.1" 200 4 "http://example\.com/" "test"\n$`)
+		equal(t, true, exp.MatchString(buf.String()))
+	})
+
+	t.Run("common", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		handler := newHandler(&httpserver.AccessLogOptions{Format: httpserver.AccessLogCommon, Output: buf})
+
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest("/healthz"))
+
+		exp := regexp.MustCompile(`^10\.0\.0\.1 - - \[.+] "GET /healthz HTTP/1\.1" 200 -\n$`)
+		equal(t, true, exp.MatchString(buf.String()))
+	})
+
+	t.Run("JSON with sampling", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		handler := newHandler(&httpserver.AccessLogOptions{
+			Logger:         slog.New(slog.NewJSONHandler(buf, nil)),
+			TrustedProxies: proxies,
+			SampleRate:     0.000001,
+		})
+
+		// server errors are always logged
+		r := newRequest("/fail")
+		r.Header.Set(trace.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		var record map[string]any
+		equal(t, nil, json.Unmarshal(buf.Bytes(), &record))
+		delete(record, "time")
+		delete(record, "duration")
+
+		equal(t, map[string]any{
+			"level":       "INFO",
+			"msg":         "access",
+			"remote_addr": "198.51.100.1",
+			"method":      "GET",
+			"route":       "/fail",
+			"uri":         "/fail",
+			"proto":       "HTTP/1.1",
+			"status":      float64(500),
+			"bytes":       float64(0),
+			"user_agent":  "test",
+			"referer":     "http://example.com/",
+			"trace_id":    "4bf92f3577b34da6a3ce929d0e0e4736",
+		}, record)
+	})
+}
@@ -0,0 +1,123 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/easy-techno-lab/proton/coder"
+	"github.com/easy-techno-lab/proton/jwt"
+	"github.com/easy-techno-lab/proton/problem"
+)
+
+// AuthenticateOptions represents the configuration of the Authenticate middleware.
+// Zero values are replaced with defaults.
+type AuthenticateOptions struct {
+	Verifier  *jwt.Verifier                // Verifier of the tokens, it is required.
+	Realm     string                       // Realm sent in the WWW-Authenticate header, it is omitted if empty.
+	Token     func(r *http.Request) string // Token of the request (BearerToken by default).
+	Formatter Formatter                    // Formatter of the 401 Unauthorized response (JSON by default).
+}
+
+// Authenticate verifies the JWT of the request and stores its claims in the request context,
+// see ClaimsFromContext. The "sub" claim is stored as the subject, see SubjectFromContext.
+// Requests without a valid token are answered with 401 Unauthorized and the WWW-Authenticate header, see RFC 6750.
+func Authenticate(opts *AuthenticateOptions) func(http.Handler) http.Handler {
+	o := AuthenticateOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Verifier == nil {
+		panic("httpserver: Authenticate requires a Verifier")
+	}
+	if o.Token == nil {
+		o.Token = BearerToken
+	}
+	if o.Formatter == nil {
+		o.Formatter = NewFormatter(coder.NewCoder("application/json", json.Marshal, json.Unmarshal, false))
+	}
+
+	var realm []string
+	if o.Realm != "" {
+		realm = []string{`realm="` + quoteEscape(o.Realm) + `"`}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			token := o.Token(r)
+			if token == "" {
+				w.Header().Set("WWW-Authenticate", challenge(realm))
+				o.Formatter.WriteError(ctx, w, problem.New(http.StatusUnauthorized, "the access token is missing"))
+				return
+			}
+
+			claims, err := o.Verifier.Verify(ctx, token)
+			if err != nil {
+				description := err.Error()
+				if !isTokenError(err) {
+					slog.ErrorContext(ctx, "verify token", "error", err)
+					description = "the access token cannot be verified"
+				}
+
+				w.Header().Set("WWW-Authenticate", challenge(append(realm,
+					`error="invalid_token"`, `error_description="`+quoteEscape(description)+`"`)))
+				o.Formatter.WriteError(ctx, w, problem.New(http.StatusUnauthorized, description))
+				return
+			}
+
+			ctx = context.WithValue(ctx, claimsCtxKey, claims)
+			if subject := claims.Subject(); subject != "" {
+				ctx = ContextWithSubject(ctx, subject)
+			}
+
+			next.ServeHTTP(w, r.Clone(ctx))
+		})
+	}
+}
+
+// BearerToken returns the token from the "Authorization: Bearer" header.
+func BearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// ClaimsFromContext returns the claims of the token verified by Authenticate.
+func ClaimsFromContext(ctx context.Context) (jwt.Claims, bool) {
+	claims, ok := ctx.Value(claimsCtxKey).(jwt.Claims)
+	return claims, ok
+}
+
+// isTokenError reports whether the token is invalid, other errors are failures of the KeySet
+// and their details are not sent to the client.
+func isTokenError(err error) bool {
+	for _, target := range []error{
+		jwt.ErrMalformed, jwt.ErrUnsupportedAlgorithm, jwt.ErrKeyNotFound, jwt.ErrInvalidSignature,
+		jwt.ErrExpired, jwt.ErrNotValidYet, jwt.ErrInvalidIssuer, jwt.ErrInvalidAudience,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// challenge returns the Bearer challenge of the WWW-Authenticate header with the parameters.
+func challenge(params []string) string {
+	if len(params) == 0 {
+		return "Bearer"
+	}
+	return "Bearer " + strings.Join(params, ", ")
+}
+
+// quoteEscape escapes the value of a quoted-string.
+func quoteEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,97 @@
+package httpserver_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/easy-techno-lab/proton/httpserver"
+	"github.com/easy-techno-lab/proton/jwt"
+	"github.com/easy-techno-lab/proton/problem"
+)
+
+func TestAuthenticate(t *testing.T) {
+	secret := []byte("secret")
+
+	sign := func(claims jwt.Claims) string {
+		token, err := jwt.Sign(jwt.HS256, "", claims, secret)
+		equal(t, nil, err)
+		return "Bearer " + token
+	}
+
+	var tests = []struct {
+		name          string
+		authorization string
+		status        int
+		challenge     string
+	}{
+		{
+			name:          "valid token",
+			authorization: sign(jwt.Claims{"sub": "user", "scope": "read", "exp": time.Now().Add(time.Minute).Unix()}),
+			status:        http.StatusOK,
+		},
+		{
+			name:      "missing token",
+			status:    http.StatusUnauthorized,
+			challenge: `Bearer realm="api"`,
+		},
+		{
+			name:          "other scheme",
+			authorization: "Basic dXNlcjpwYXNz",
+			status:        http.StatusUnauthorized,
+			challenge:     `Bearer realm="api"`,
+		},
+		{
+			name:          "expired token",
+			authorization: sign(jwt.Claims{"sub": "user", "exp": time.Now().Add(-time.Minute).Unix()}),
+			status:        http.StatusUnauthorized,
+			challenge:     `Bearer realm="api", error="invalid_token", error_description="token is expired"`,
+		},
+		{
+			name:          "invalid signature",
+			authorization: sign(jwt.Claims{"sub": "user"}) + "x",
+			status:        http.StatusUnauthorized,
+			challenge:     `Bearer realm="api", error="invalid_token", error_description="invalid token signature"`,
+		},
+	}
+
+	handler := httpserver.Authenticate(&httpserver.AuthenticateOptions{
+		Verifier: &jwt.Verifier{Keys: jwt.HMACKey("", secret)},
+		Realm:    "api",
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := httpserver.ClaimsFromContext(r.Context())
+		equal(t, true, ok)
+		equal(t, true, claims.HasScope("read"))
+
+		subject, ok := httpserver.SubjectFromContext(r.Context())
+		equal(t, true, ok)
+		equal(t, "user", subject)
+	}))
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.authorization != "" {
+				r.Header.Set("Authorization", test.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			equal(t, test.status, w.Code)
+			equal(t, test.challenge, w.Header().Get("WWW-Authenticate"))
+			if test.status == http.StatusUnauthorized {
+				equal(t, true, strings.HasPrefix(w.Header().Get("Content-Type"), problem.ContentType))
+			}
+		})
+	}
+}
+
+func TestAuthenticate_NilOptions(t *testing.T) {
+	defer func() {
+		equal(t, "httpserver: Authenticate requires a Verifier", recover())
+	}()
+	httpserver.Authenticate(nil)
+}
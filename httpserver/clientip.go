@@ -0,0 +1,74 @@
+package httpserver
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies is a list of networks of the proxies whose X-Forwarded-For headers are trusted.
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies parses IP addresses and CIDR networks.
+func ParseTrustedProxies(proxies ...string) (TrustedProxies, error) {
+	p := make(TrustedProxies, 0, len(proxies))
+	for _, s := range proxies {
+		if strings.Contains(s, "/") {
+			prefix, err := netip.ParsePrefix(s)
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return p, nil
+}
+
+// Contains reports whether the address belongs to one of the networks.
+func (p TrustedProxies) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the IP address of the client which sent the request.
+// X-Forwarded-For is used only if the request came from a trusted proxy, it is read from right to left
+// skipping trusted proxies. If all of them are trusted or an entry cannot be parsed, the last trusted hop
+// is returned. X-Real-IP is not used, since proxies often pass it from the client as is.
+// If the address cannot be determined, the host of RemoteAddr is returned as is.
+func (p TrustedProxies) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	remote, err := netip.ParseAddr(host)
+	if err != nil || !p.Contains(remote) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+		if !p.Contains(addr) {
+			return addr.Unmap().String()
+		}
+		remote = addr
+	}
+
+	return remote.Unmap().String()
+}
@@ -0,0 +1,402 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// RestartMode defines how Restart replaces the server.
+type RestartMode int
+
+const (
+	// RestartShutdown shuts the server down, then the new server listens on Addr.
+	// The port refuses connections while the server restarts.
+	RestartShutdown RestartMode = iota
+	// RestartHandoff starts the new server on the listener of the old one, or on a new listener if Addr has changed,
+	// then the old server is shut down gracefully. The connections are accepted during the whole restart.
+	RestartHandoff
+	// RestartExec starts the executable of the process again and passes the listener to it,
+	// then the old server is shut down gracefully and Start returns. It is used to upgrade the binary.
+	RestartExec
+)
+
+// Controller is a wrapper around *http.Server to control the server.
+//
+//	Server — *http.Server, which will be managed.
+//	GracefulTimeout — time that is given to the server to shut down gracefully.
+//	Listen — creates the listener for Addr (ListenTCP(nil) by default), see ListenUnix and ListenSystemd.
+//	RestartMode — how Restart replaces the server (RestartShutdown by default).
+//	Signals — signals that shut down the server started with Start (SIGINT and SIGTERM by default).
+//	OnStart — called every time the server starts serving, including after a restart.
+//	OnRestart — called when a restart begins.
+//	OnShutdown — called when a graceful shutdown begins, ctx is done when the GracefulTimeout expires.
+//	The GracefulTimeout of the server starts when it returns.
+type Controller struct {
+	Server          *http.Server
+	GracefulTimeout time.Duration
+	Listen          ListenFunc
+	RestartMode     RestartMode
+	Signals         []os.Signal
+
+	OnStart    func(ctx context.Context)
+	OnRestart  func(ctx context.Context)
+	OnShutdown func(ctx context.Context)
+
+	isRan    atomic.Bool
+	restarts chan struct{}
+	errc     <-chan error
+	bound    atomic.Pointer[boundListener]
+	draining sync.WaitGroup
+
+	mu       sync.Mutex // guards Server while it is replaced during a restart, stopping and ready
+	stopping bool       // Shutdown has been called, the server replaced during a restart must be shut down too
+	ready    chan struct{}
+}
+
+// boundListener is the listener of the running server and the Addr it has been bound for.
+type boundListener struct {
+	addr string
+	ln   net.Listener
+}
+
+// Start starts the *http.Server.
+// If *tls.Config on the server is non nil, the server listens and serves using tls.
+// The listener is created by Listen, or, if the process was started by a Controller with RestartExec,
+// the server serves on the inherited listener.
+// The server is shut down gracefully when one of the Signals is received.
+func (c *Controller) Start() error {
+	signals := c.Signals
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), signals...)
+	defer stop()
+
+	return c.Run(ctx)
+}
+
+// Run starts the *http.Server like Start, and serves until ctx is done, then shuts the server down gracefully.
+// Unlike Start, it does not handle signals, so that it can be used in tests and in applications
+// that handle signals themselves.
+func (c *Controller) Run(ctx context.Context) error {
+	if err := c.bind(ctx); err != nil {
+		return err
+	}
+
+	notifyReady()
+
+	return c.wait(ctx)
+}
+
+// Ready returns a channel that is closed when the listener is bound for the first time.
+func (c *Controller) Ready() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ready == nil {
+		c.ready = make(chan struct{})
+	}
+	return c.ready
+}
+
+// bind binds the listener and starts serving.
+func (c *Controller) bind(ctx context.Context) error {
+	ln, err := c.listen(ctx, c.Server.Addr)
+	if err != nil {
+		return fmt.Errorf("HTTP server listen: %w", err)
+	}
+
+	c.restarts = make(chan struct{}, 1)
+	c.isRan.Store(true)
+
+	c.errc = c.serve(c.Server, ln, c.Server.Addr)
+
+	c.mu.Lock()
+	c.stopping = false
+	if c.ready == nil {
+		c.ready = make(chan struct{})
+	}
+	select {
+	case <-c.ready:
+	default:
+		close(c.ready)
+	}
+	c.mu.Unlock()
+
+	hook(ctx, c.OnStart)
+
+	return nil
+}
+
+// wait serves until ctx is done, the server is shut down, or fails, and restarts the server on request.
+func (c *Controller) wait(ctx context.Context) error {
+	defer c.isRan.Store(false)
+	defer c.draining.Wait()
+
+	srv, errc := c.Server, c.errc
+
+	for {
+		select {
+		case err := <-errc:
+			if errors.Is(err, http.ErrServerClosed) {
+				slog.Info("HTTP server is shutdown")
+				return nil
+			}
+			return fmt.Errorf("HTTP server Serve: %w", err)
+
+		case <-ctx.Done():
+			c.Shutdown()
+			<-errc
+			slog.Info("HTTP server is shutdown")
+			return nil
+
+		case <-c.restarts:
+			slog.Info("HTTP server is restarting", "mode", c.RestartMode.String())
+
+			hook(ctx, c.OnRestart)
+
+			b := c.bound.Load()
+
+			switch c.RestartMode {
+			case RestartHandoff:
+				ln, err := c.handoff(ctx, b)
+				if err != nil {
+					c.setServer(srv)
+					slog.Error(fmt.Sprintf("HTTP server restart: %s", err))
+					break // the old server keeps serving
+				}
+				c.drain(srv, errc)
+				errc = c.serve(c.Server, ln, c.Server.Addr)
+
+			case RestartExec:
+				if err := execWithListeners(map[string]net.Listener{b.addr: b.ln}); err != nil {
+					slog.Error(fmt.Sprintf("HTTP server restart: %s", err))
+					break // the old server keeps serving
+				}
+				c.shutdown(srv)
+				<-errc
+				slog.Info("HTTP server is handed over to the new process")
+				return nil
+
+			default:
+				c.shutdown(srv)
+				<-errc
+				c.clone()
+				ln, err := c.listen(ctx, c.Server.Addr)
+				if err != nil {
+					return fmt.Errorf("HTTP server listen: %w", err)
+				}
+				errc = c.serve(c.Server, ln, c.Server.Addr)
+			}
+
+			srv = c.Server
+
+			if c.isStopping() {
+				// Shutdown has been called during the restart and may have missed the new server
+				c.shutdown(srv)
+				<-errc
+				slog.Info("HTTP server is shutdown")
+				return nil
+			}
+
+			hook(ctx, c.OnStart)
+		}
+	}
+}
+
+// Restart restarts the server if necessary.
+// For changes to the following parameters to take effect:
+//
+//	Addr; TLSConfig; TLSNextProto; ConnState; BaseContext; ConnContext,
+//
+// a server restart is required.
+// Other parameters can be changed without restarting the server.
+// If the server is not running, the function will be skipped.
+func (c *Controller) Restart() {
+	if !c.isRan.Load() {
+		return
+	}
+
+	select {
+	case c.restarts <- struct{}{}:
+	default: // a restart is already pending
+	}
+}
+
+// Shutdown gracefully shuts down the server.
+func (c *Controller) Shutdown() {
+	c.onShutdown(c.GracefulTimeout)
+
+	ctx, cancelWithTimeout := context.WithTimeout(context.Background(), c.GracefulTimeout)
+	defer cancelWithTimeout()
+
+	c.shutdownContext(ctx)
+}
+
+// onShutdown calls OnShutdown with a context that is done after timeout.
+func (c *Controller) onShutdown(timeout time.Duration) {
+	ctx, cancelWithTimeout := context.WithTimeout(context.Background(), timeout)
+	defer cancelWithTimeout()
+
+	hook(ctx, c.OnShutdown)
+}
+
+// shutdownContext gracefully shuts down the server until ctx is done, OnShutdown must be called before.
+func (c *Controller) shutdownContext(ctx context.Context) {
+	c.mu.Lock()
+	c.stopping = true
+	srv := c.Server
+	c.mu.Unlock()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error(fmt.Sprintf("HTTP server shutdown: %s", err))
+	}
+}
+
+// Addr returns the address the server is listening on, or nil if the server is not running.
+// It is useful when the server listens on a random port, for example "localhost:0".
+func (c *Controller) Addr() net.Addr {
+	if b := c.bound.Load(); b != nil && c.isRan.Load() {
+		return b.ln.Addr()
+	}
+	return nil
+}
+
+// listen returns the listener inherited from the parent process for the address, or a new one created by Listen.
+func (c *Controller) listen(ctx context.Context, addr string) (net.Listener, error) {
+	if ln, err := inheritedListener(addr); ln != nil || err != nil {
+		return ln, err
+	}
+
+	if c.Listen != nil {
+		return c.Listen(ctx, addr)
+	}
+
+	return ListenTCP(nil)(ctx, addr)
+}
+
+// shutdown shuts down the replaced server during a restart.
+func (c *Controller) shutdown(srv *http.Server) {
+	ctx, cancelWithTimeout := context.WithTimeout(context.Background(), c.GracefulTimeout)
+	defer cancelWithTimeout()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error(fmt.Sprintf("HTTP server shutdown: %s", err))
+	}
+}
+
+// serve serves the listener in a goroutine, the returned channel receives the error of the server.
+func (c *Controller) serve(srv *http.Server, ln net.Listener, addr string) <-chan error {
+	c.bound.Store(&boundListener{addr: addr, ln: ln})
+
+	secure := srv.TLSConfig != nil
+
+	slog.Info("HTTP server serving", "secure", secure, "address", ln.Addr().String())
+
+	errc := make(chan error, 1)
+	go func() {
+		if secure {
+			errc <- srv.ServeTLS(ln, "", "")
+		} else {
+			errc <- srv.Serve(ln)
+		}
+	}()
+
+	return errc
+}
+
+// handoff prepares the new server and its listener: a duplicate of the listener if Addr has not changed,
+// so that the old server can close its own, or a new one.
+func (c *Controller) handoff(ctx context.Context, b *boundListener) (net.Listener, error) {
+	c.clone()
+
+	if c.Server.Addr != b.addr {
+		return c.listen(ctx, c.Server.Addr)
+	}
+
+	return dupListener(b.ln)
+}
+
+// drain shuts the old server down in the background, Start waits for it before returning.
+func (c *Controller) drain(srv *http.Server, errc <-chan error) {
+	c.draining.Add(1)
+	go func() {
+		defer c.draining.Done()
+		c.shutdown(srv)
+		<-errc
+	}()
+}
+
+// clone clones the server before restarting, since it is impossible to start a stopped server.
+func (c *Controller) clone() {
+	old := c.Server
+
+	tlsConfig := old.TLSConfig
+	if tlsConfig != nil && len(tlsConfig.Certificates) == 0 &&
+		tlsConfig.GetCertificate == nil && tlsConfig.GetConfigForClient == nil {
+		tlsConfig = nil
+	}
+
+	c.setServer(&http.Server{
+		Addr:                         old.Addr, // need to restart
+		Handler:                      old.Handler,
+		DisableGeneralOptionsHandler: old.DisableGeneralOptionsHandler,
+		TLSConfig:                    tlsConfig, // need to restart
+		ReadTimeout:                  old.ReadTimeout,
+		ReadHeaderTimeout:            old.ReadHeaderTimeout,
+		WriteTimeout:                 old.WriteTimeout,
+		IdleTimeout:                  old.IdleTimeout,
+		MaxHeaderBytes:               old.MaxHeaderBytes,
+		TLSNextProto:                 old.TLSNextProto, // need to restart
+		ConnState:                    old.ConnState,    // need to restart
+		ErrorLog:                     old.ErrorLog,
+		BaseContext:                  old.BaseContext, // need to restart
+		ConnContext:                  old.ConnContext, // need to restart
+	})
+}
+
+// setServer replaces the server, so that Shutdown called from another goroutine sees either the old or the new one.
+func (c *Controller) setServer(srv *http.Server) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Server = srv
+}
+
+// isStopping reports whether Shutdown has been called.
+func (c *Controller) isStopping() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stopping
+}
+
+func hook(ctx context.Context, f func(ctx context.Context)) {
+	if f != nil {
+		f(ctx)
+	}
+}
+
+func (m RestartMode) String() string {
+	switch m {
+	case RestartShutdown:
+		return "shutdown"
+	case RestartHandoff:
+		return "handoff"
+	case RestartExec:
+		return "exec"
+	default:
+		return fmt.Sprintf("RestartMode(%d)", int(m))
+	}
+}
@@ -0,0 +1,396 @@
+package httpserver_test
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/easy-techno-lab/proton/httpserver"
+)
+
+// text returns a handler which writes the text, requests to /slow wait until release is closed.
+func text(s string, release <-chan struct{}, started chan<- struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			<-release
+		}
+		_, _ = io.WriteString(w, s)
+	})
+}
+
+func get(t *testing.T, url string) string {
+	resp, err := http.Get(url)
+	equal(t, nil, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	equal(t, nil, err)
+
+	return string(body)
+}
+
+// start starts the Controller and waits until it listens.
+func start(t *testing.T, hcr *httpserver.Controller) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- hcr.Start() }()
+
+	select {
+	case <-hcr.Ready():
+	case <-time.After(time.Second):
+		t.Fatal("the server is not started")
+	}
+
+	return done
+}
+
+// waitAddr waits until the Controller listens on an address other than prev.
+func waitAddr(t *testing.T, hcr *httpserver.Controller, prev string) string {
+	for i := 0; ; i++ {
+		if addr := hcr.Addr(); addr != nil && addr.String() != prev {
+			return addr.String()
+		}
+		if i == 100 {
+			t.Fatal("the server is not restarted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestController_Restart(t *testing.T) {
+	hcr := &httpserver.Controller{
+		Server:          &http.Server{Addr: "127.0.0.1:0", Handler: text("old", nil, nil)},
+		GracefulTimeout: 5 * time.Second,
+	}
+
+	done := start(t, hcr)
+	addr := hcr.Addr().String()
+
+	equal(t, "old", get(t, "http://"+addr))
+
+	// a random port is chosen again
+	hcr.Server.Handler = text("new", nil, nil)
+	hcr.Restart()
+
+	addr = waitAddr(t, hcr, addr)
+	equal(t, "new", get(t, "http://"+addr))
+
+	hcr.Shutdown()
+	equal(t, nil, <-done)
+	equal(t, nil, hcr.Addr())
+}
+
+func TestController_Run(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+
+	event := func(name string) func(ctx context.Context) {
+		return func(ctx context.Context) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, name)
+		}
+	}
+
+	hcr := &httpserver.Controller{
+		Server:      &http.Server{Addr: "127.0.0.1:0", Handler: text("ok", nil, nil)},
+		RestartMode: httpserver.RestartHandoff,
+		OnStart:     event("start"),
+		OnRestart:   event("restart"),
+		OnShutdown: func(ctx context.Context) {
+			_, ok := ctx.Deadline()
+			equal(t, true, ok)
+			event("shutdown")(ctx)
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- hcr.Run(ctx) }()
+
+	<-hcr.Ready()
+	addr := hcr.Addr().String()
+	equal(t, "ok", get(t, "http://"+addr))
+
+	// Restart does not depend on signals
+	hcr.Restart()
+	for i := 0; ; i++ {
+		mu.Lock()
+		n := len(events)
+		mu.Unlock()
+		if n == 3 {
+			break
+		}
+		if i == 100 {
+			t.Fatal("the server is not restarted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	equal(t, "ok", get(t, "http://"+addr))
+
+	cancel()
+	equal(t, nil, <-done)
+
+	mu.Lock()
+	defer mu.Unlock()
+	equal(t, []string{"start", "restart", "start", "shutdown"}, events)
+}
+
+func TestController_RestartHandoff(t *testing.T) {
+	release, started := make(chan struct{}), make(chan struct{}, 1)
+
+	hcr := &httpserver.Controller{
+		Server:          &http.Server{Addr: "127.0.0.1:0", Handler: text("old", release, started)},
+		GracefulTimeout: 5 * time.Second,
+		RestartMode:     httpserver.RestartHandoff,
+	}
+
+	done := start(t, hcr)
+	addr := hcr.Addr().String()
+
+	slow := make(chan string, 1)
+	go func() { slow <- get(t, "http://"+addr+"/slow") }()
+	<-started
+
+	hcr.Server.Handler = text("new", nil, nil)
+	hcr.Restart()
+
+	// the new server accepts on the same listener while the old one is serving the request
+	for i := 0; get(t, "http://"+addr) != "new"; i++ {
+		if i == 100 {
+			t.Fatal("the server is not restarted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	equal(t, addr, hcr.Addr().String())
+
+	close(release)
+	equal(t, "old", <-slow)
+
+	// the new server listens on the new address
+	hcr.Server.Addr = "localhost:0"
+	hcr.Restart()
+
+	newAddr := waitAddr(t, hcr, addr)
+	equal(t, "new", get(t, "http://"+newAddr))
+
+	hcr.Shutdown()
+	equal(t, nil, <-done)
+}
+
+func TestController_ShutdownDuringRestart(t *testing.T) {
+	for _, mode := range []httpserver.RestartMode{httpserver.RestartShutdown, httpserver.RestartHandoff} {
+		t.Run(mode.String(), func(t *testing.T) {
+			hcr := &httpserver.Controller{
+				Server:          &http.Server{Addr: "127.0.0.1:0", Handler: text("ok", nil, nil)},
+				GracefulTimeout: time.Second,
+				RestartMode:     mode,
+			}
+
+			// Shutdown races with the replacement of the server
+			hcr.OnRestart = func(context.Context) { go hcr.Shutdown() }
+
+			done := start(t, hcr)
+			equal(t, "ok", get(t, "http://"+hcr.Addr().String()))
+
+			hcr.Restart()
+
+			select {
+			case err := <-done:
+				equal(t, nil, err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("the server is not shut down")
+			}
+		})
+	}
+}
+
+func TestController_RestartExec(t *testing.T) {
+	// the new process is this test started again
+	if os.Getenv(httpserver.ListenFDsEnv) != "" {
+		hcr := &httpserver.Controller{Server: &http.Server{Addr: "127.0.0.1:0"}}
+		hcr.Server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/exit" {
+				go hcr.Shutdown()
+			}
+			_, _ = io.WriteString(w, "child")
+		})
+		if err := hcr.Start(); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	hcr := &httpserver.Controller{
+		Server:          &http.Server{Addr: "127.0.0.1:0", Handler: text("parent", nil, nil)},
+		GracefulTimeout: 5 * time.Second,
+		RestartMode:     httpserver.RestartExec,
+	}
+
+	done := start(t, hcr)
+	addr := hcr.Addr().String()
+
+	equal(t, "parent", get(t, "http://"+addr))
+
+	args, stdout, stderr := os.Args, os.Stdout, os.Stderr
+	defer func() { os.Args, os.Stdout, os.Stderr = args, stdout, stderr }()
+
+	devNull, err := os.Open(os.DevNull)
+	equal(t, nil, err)
+	defer func() { _ = devNull.Close() }()
+
+	os.Args = []string{args[0], "-test.run=^TestController_RestartExec$"}
+	os.Stdout, os.Stderr = devNull, devNull
+
+	hcr.Restart()
+	equal(t, nil, <-done)
+
+	// the listener is served by the new process
+	equal(t, "child", get(t, "http://"+addr+"/exit"))
+}
+
+func TestGroup(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+
+	newController := func(name string) *httpserver.Controller {
+		srv := &http.Server{Addr: "127.0.0.1:0", Handler: text(name, nil, nil)}
+		srv.RegisterOnShutdown(func() {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+		})
+		return &httpserver.Controller{Server: srv, GracefulTimeout: time.Second}
+	}
+
+	g := &httpserver.Group{
+		Controllers: []*httpserver.Controller{newController("public"), newController("admin")},
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- g.Start() }()
+
+	for _, c := range g.Controllers {
+		waitAddr(t, c, "")
+	}
+
+	equal(t, "public", get(t, "http://"+g.Controllers[0].Addr().String()))
+	equal(t, "admin", get(t, "http://"+g.Controllers[1].Addr().String()))
+
+	g.Shutdown()
+	equal(t, nil, <-done)
+
+	mu.Lock()
+	defer mu.Unlock()
+	equal(t, []string{"public", "admin"}, order)
+}
+
+func TestGroup_Failure(t *testing.T) {
+	var tests = []struct {
+		name   string
+		failed *http.Server
+	}{
+		{
+			name:   "listen",
+			failed: &http.Server{Addr: "127.0.0.1:-1"},
+		},
+		{
+			name:   "serve",
+			failed: &http.Server{Addr: "127.0.0.1:0", TLSConfig: &tls.Config{}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := &httpserver.Group{
+				Controllers: []*httpserver.Controller{
+					{Server: &http.Server{Addr: "127.0.0.1:0", Handler: text("ok", nil, nil)}},
+					{Server: test.failed},
+				},
+				ShutdownTimeout: time.Second,
+			}
+
+			err := g.Start()
+			equal(t, true, err != nil)
+			equal(t, nil, g.Controllers[0].Addr())
+		})
+	}
+}
+
+func TestGroup_RestartExec(t *testing.T) {
+	newGroup := func(addrs []string, prefix string) *httpserver.Group {
+		return &httpserver.Group{
+			Controllers: []*httpserver.Controller{
+				{
+					Server:          &http.Server{Addr: addrs[0], Handler: text(prefix+"public", nil, nil)},
+					GracefulTimeout: 5 * time.Second,
+					RestartMode:     httpserver.RestartExec,
+				},
+				{
+					Server:          &http.Server{Addr: addrs[1], Handler: text(prefix+"admin", nil, nil)},
+					GracefulTimeout: 5 * time.Second,
+					RestartMode:     httpserver.RestartHandoff,
+				},
+			},
+		}
+	}
+
+	// the new process is this test started again, it binds both addresses
+	if addrs := os.Getenv("TEST_GROUP_ADDRS"); addrs != "" && os.Getenv(httpserver.ListenFDsEnv) != "" {
+		g := newGroup(strings.Split(addrs, ","), "child ")
+		g.Controllers[0].Server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			go g.Shutdown()
+			_, _ = io.WriteString(w, "child public")
+		})
+		if err := g.Start(); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// the addresses must be known to the new process, so they are not random
+	var addrs []string
+	for i := 0; i < 2; i++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		equal(t, nil, err)
+		addrs = append(addrs, ln.Addr().String())
+		equal(t, nil, ln.Close())
+	}
+
+	g := newGroup(addrs, "")
+
+	done := make(chan error, 1)
+	go func() { done <- g.Start() }()
+
+	for _, c := range g.Controllers {
+		waitAddr(t, c, "")
+	}
+	equal(t, "admin", get(t, "http://"+addrs[1]))
+
+	args, stdout, stderr := os.Args, os.Stdout, os.Stderr
+	defer func() { os.Args, os.Stdout, os.Stderr = args, stdout, stderr }()
+
+	devNull, err := os.Open(os.DevNull)
+	equal(t, nil, err)
+	defer func() { _ = devNull.Close() }()
+
+	os.Args = []string{args[0], "-test.run=^TestGroup_RestartExec$"}
+	os.Stdout, os.Stderr = devNull, devNull
+	t.Setenv("TEST_GROUP_ADDRS", strings.Join(addrs, ","))
+
+	g.Restart()
+	equal(t, nil, <-done)
+
+	// both listeners are served by the new process
+	equal(t, "child admin", get(t, "http://"+addrs[1]))
+	equal(t, "child public", get(t, "http://"+addrs[0]))
+}
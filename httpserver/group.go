@@ -0,0 +1,164 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Group controls several servers together, for example a public, an internal and an admin server.
+//
+//	Controllers — Controllers of the servers, they are shut down in this order.
+//	ShutdownTimeout — time that is given to all servers together to shut down gracefully
+//	(the longest GracefulTimeout of the Controllers by default).
+type Group struct {
+	Controllers     []*Controller
+	ShutdownTimeout time.Duration
+}
+
+// Start starts all servers and waits until they stop.
+// The servers are shut down on SIGINT or SIGTERM, or when one of them fails.
+// The returned error joins the errors of all servers.
+func (g *Group) Start() error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	return g.Run(ctx)
+}
+
+// Run starts all servers like Start, and waits until they stop or ctx is done. It does not handle signals.
+func (g *Group) Run(ctx context.Context) error {
+	// the Controllers are stopped by the Group in order, not by ctx
+	serveCtx := context.WithoutCancel(ctx)
+
+	for i, c := range g.Controllers {
+		if err := c.bind(serveCtx); err != nil {
+			started := g.Controllers[:i]
+			shutdownAll(started, g.shutdownTimeout())
+			errs := []error{err}
+			for _, s := range started {
+				errs = append(errs, s.wait(serveCtx))
+			}
+			return errors.Join(errs...)
+		}
+	}
+
+	notifyReady()
+
+	results := make(chan error, len(g.Controllers))
+	for _, c := range g.Controllers {
+		go func(c *Controller) {
+			results <- c.wait(serveCtx)
+		}(c)
+	}
+
+	var errs []error
+	done := ctx.Done()
+
+	for range g.Controllers {
+		select {
+		case <-done:
+			done = nil
+			g.Shutdown()
+			errs = append(errs, <-results)
+		case err := <-results:
+			if err != nil && done != nil {
+				done = nil
+				g.Shutdown()
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Restart restarts the servers according to their RestartMode, see Controller.Restart.
+// If any of the servers has RestartExec, the whole process is restarted instead: the new process binds all servers,
+// so the listeners of all of them are passed to it, then all servers are shut down.
+func (g *Group) Restart() {
+	listeners := make(map[string]net.Listener)
+	var running []*Controller
+	var exec bool
+
+	for _, c := range g.Controllers {
+		if !c.isRan.Load() {
+			continue
+		}
+		b := c.bound.Load()
+		listeners[b.addr] = b.ln
+		running = append(running, c)
+		exec = exec || c.RestartMode == RestartExec
+	}
+
+	if !exec {
+		for _, c := range running {
+			c.Restart()
+		}
+		return
+	}
+
+	slog.Info("HTTP servers are restarting", "mode", RestartExec.String())
+
+	if err := execWithListeners(listeners); err != nil {
+		slog.Error(fmt.Sprintf("HTTP servers restart: %s", err))
+		return
+	}
+
+	shutdownAll(running, g.shutdownTimeout())
+
+	slog.Info("HTTP servers are handed over to the new process")
+}
+
+// Shutdown gracefully shuts down the servers one by one in the order of Controllers.
+// The OnShutdown hooks of all servers are called together first, then the servers share ShutdownTimeout,
+// which starts when the hooks return. The hooks are given ShutdownTimeout as well.
+func (g *Group) Shutdown() {
+	shutdownAll(g.Controllers, g.shutdownTimeout())
+}
+
+func (g *Group) shutdownTimeout() time.Duration {
+	if g.ShutdownTimeout > 0 {
+		return g.ShutdownTimeout
+	}
+
+	var timeout time.Duration
+	for _, c := range g.Controllers {
+		timeout = max(timeout, c.GracefulTimeout)
+	}
+	return timeout
+}
+
+// shutdownAll calls the OnShutdown hooks of the running servers concurrently,
+// then shuts the servers down in order within the timeout.
+func shutdownAll(controllers []*Controller, timeout time.Duration) {
+	var running []*Controller
+	for _, c := range controllers {
+		if c.isRan.Load() {
+			running = append(running, c)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range running {
+		wg.Add(1)
+		go func(c *Controller) {
+			defer wg.Done()
+			c.onShutdown(timeout)
+		}(c)
+	}
+	wg.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for _, c := range running {
+		c.shutdownContext(ctx)
+	}
+}
@@ -0,0 +1,194 @@
+package httpserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/easy-techno-lab/proton/problem"
+)
+
+// StatusCoder is implemented by errors and response values that define the HTTP status code of the response.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// Handle returns an http.Handler that decodes the request into In, calls fn and writes Out with the Formatter.
+//
+// The request body is decoded with the Formatter, then the fields of In tagged with
+//
+//	`path:"name"`, `query:"name"`, `header:"Name"`
+//
+// are set from the path wildcards, query parameters and headers, slices receive all values.
+// The fields of embedded structs are set too, nil embedded pointers are allocated when their parameters are present.
+// If the request cannot be decoded, the response is 400 Bad Request.
+//
+// The response status code is taken from Out if it implements StatusCoder, 204 No Content is used for a nil Out,
+// 200 OK otherwise. Errors returned by fn are written with Formatter.WriteError.
+func Handle[In, Out any](f Formatter, fn func(ctx context.Context, in In) (Out, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var in In
+		if err := decodeRequest(f, r, &in); err != nil {
+			f.WriteError(ctx, w, problem.Wrap(http.StatusBadRequest, err))
+			return
+		}
+
+		out, err := fn(ctx, in)
+		if err != nil {
+			f.WriteError(ctx, w, err)
+			return
+		}
+
+		v := any(out)
+		if isNil(v) {
+			f.WriteResponse(ctx, w, http.StatusNoContent, nil)
+			return
+		}
+
+		statusCode := http.StatusOK
+		if sc, ok := v.(StatusCoder); ok {
+			statusCode = sc.StatusCode()
+		}
+
+		f.WriteResponse(ctx, w, statusCode, v)
+	})
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	default:
+		return false
+	}
+}
+
+// decodeRequest decodes the request body and the tagged parameters into the value pointed to by v.
+func decodeRequest(f Formatter, r *http.Request, v any) error {
+	rv := reflect.ValueOf(v).Elem()
+	if rv.Kind() == reflect.Pointer {
+		rv.Set(reflect.New(rv.Type().Elem()))
+		v = rv.Interface()
+		rv = rv.Elem()
+	}
+
+	if r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0 {
+		if err := f.Decode(r.Context(), r.Body, v); err != nil {
+			return fmt.Errorf("decode body: %w", err)
+		}
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for _, p := range paramsOf(rv.Type()) {
+		var values []string
+		switch p.source {
+		case "path":
+			if s := r.PathValue(p.name); s != "" {
+				values = []string{s}
+			}
+		case "query":
+			values = r.URL.Query()[p.name]
+		case "header":
+			values = r.Header.Values(p.name)
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+
+		field, ok := fieldByIndex(rv, p.index)
+		if !ok {
+			continue
+		}
+
+		if err := setParam(field, values); err != nil {
+			return fmt.Errorf("%s parameter %q: %w", p.source, p.name, err)
+		}
+	}
+
+	return nil
+}
+
+// fieldByIndex returns the nested field, allocating the nil embedded pointers on the way.
+// It reports false if a nil embedded pointer cannot be set, since its struct type is unexported.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// setParam sets the values to the field, slices receive all values, other types only the first one.
+func setParam(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		field = field.Elem()
+	}
+
+	if field.Kind() != reflect.Slice {
+		return parseParam(field, values[0])
+	}
+
+	slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+	for i, s := range values {
+		if err := parseParam(slice.Index(i), s); err != nil {
+			return err
+		}
+	}
+	field.Set(slice)
+
+	return nil
+}
+
+// param is a struct field tagged as a request parameter.
+type param struct {
+	source string // path, query or header
+	name   string
+	index  []int
+}
+
+var paramsCache sync.Map // map[reflect.Type][]param
+
+// paramsOf returns the tagged fields of the struct type including the fields of embedded structs.
+func paramsOf(t reflect.Type) []param {
+	if cached, ok := paramsCache.Load(t); ok {
+		return cached.([]param)
+	}
+
+	var params []param
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() {
+			continue
+		}
+		for _, source := range []string{"path", "query", "header"} {
+			if name, ok := field.Tag.Lookup(source); ok && name != "" && name != "-" {
+				params = append(params, param{source: source, name: name, index: field.Index})
+			}
+		}
+	}
+
+	paramsCache.Store(t, params)
+
+	return params
+}
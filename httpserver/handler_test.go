@@ -0,0 +1,176 @@
+package httpserver_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/easy-techno-lab/proton/httpserver"
+)
+
+type handleIn struct {
+	ID     int      `json:"-" path:"id"`
+	Tags   []string `json:"-" query:"tag"`
+	Limit  *int     `json:"-" query:"limit"`
+	Client string   `json:"-" header:"X-Client"`
+	Name   string   `json:"name"`
+}
+
+type Pagination struct {
+	Page int `query:"page"`
+}
+
+type sorting struct {
+	Sort string `query:"sort"`
+}
+
+// listIn has the parameters promoted through nil embedded pointers,
+// the parameters of the unexported one cannot be set.
+type listIn struct {
+	*Pagination
+	*sorting
+}
+
+type handleOut struct {
+	ID     int      `json:"id"`
+	Tags   []string `json:"tags"`
+	Limit  int      `json:"limit"`
+	Client string   `json:"client"`
+	Name   string   `json:"name"`
+}
+
+type createdOut struct {
+	ID int `json:"id"`
+}
+
+func (createdOut) StatusCode() int {
+	return http.StatusCreated
+}
+
+type notFoundError struct{}
+
+func (notFoundError) Error() string {
+	return "user not found"
+}
+
+func (notFoundError) StatusCode() int {
+	return http.StatusNotFound
+}
+
+func TestHandle(t *testing.T) {
+	fmtJSON := httpserver.NewFormatter(cdrJSON)
+
+	router := httpserver.NewRouter()
+
+	router.Handle("PUT /users/{id}", httpserver.Handle(fmtJSON, func(_ context.Context, in *handleIn) (*handleOut, error) {
+		switch in.ID {
+		case 0:
+			return nil, nil
+		case 404:
+			return nil, notFoundError{}
+		case 500:
+			return nil, errors.New("database is down")
+		}
+		out := &handleOut{ID: in.ID, Tags: in.Tags, Client: in.Client, Name: in.Name}
+		if in.Limit != nil {
+			out.Limit = *in.Limit
+		}
+		return out, nil
+	}))
+
+	router.Handle("POST /users", httpserver.Handle(fmtJSON, func(_ context.Context, in handleIn) (createdOut, error) {
+		return createdOut{ID: 1}, nil
+	}))
+
+	router.Handle("GET /users", httpserver.Handle(fmtJSON, func(_ context.Context, in listIn) (map[string]any, error) {
+		out := map[string]any{"sorted": in.sorting != nil}
+		if in.Pagination != nil {
+			out["page"] = in.Page
+		}
+		return out, nil
+	}))
+
+	var tests = []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		statusCode int
+		respBody   string
+	}{
+		{
+			name:       "body, path, query and header",
+			method:     http.MethodPut,
+			target:     "/users/7?tag=a&tag=b&limit=10",
+			body:       `{"name":"example"}`,
+			statusCode: http.StatusOK,
+			respBody:   `{"id":7,"tags":["a","b"],"limit":10,"client":"test","name":"example"}`,
+		},
+		{
+			name:       "status code from output",
+			method:     http.MethodPost,
+			target:     "/users",
+			body:       `{"name":"example"}`,
+			statusCode: http.StatusCreated,
+			respBody:   `{"id":1}`,
+		},
+		{
+			name:       "embedded pointers",
+			method:     http.MethodGet,
+			target:     "/users?page=2&sort=name",
+			statusCode: http.StatusOK,
+			respBody:   `{"page":2,"sorted":false}`,
+		},
+		{
+			name:       "nil output",
+			method:     http.MethodPut,
+			target:     "/users/0",
+			statusCode: http.StatusNoContent,
+		},
+		{
+			name:       "invalid body",
+			method:     http.MethodPut,
+			target:     "/users/7",
+			body:       `{"name":`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid query parameter",
+			method:     http.MethodPut,
+			target:     "/users/7?limit=ten",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "typed error",
+			method:     http.MethodPut,
+			target:     "/users/404",
+			statusCode: http.StatusNotFound,
+			respBody:   `{"detail":"user not found","status":404,"title":"Not Found"}`,
+		},
+		{
+			name:       "internal error",
+			method:     http.MethodPut,
+			target:     "/users/500",
+			statusCode: http.StatusInternalServerError,
+			respBody:   `{"status":500,"title":"Internal Server Error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			r.Header.Set("X-Client", "test")
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			equal(t, test.statusCode, w.Code)
+			if test.respBody != "" {
+				equal(t, test.respBody, strings.TrimSpace(w.Body.String()))
+			}
+		})
+	}
+}
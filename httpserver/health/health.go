@@ -0,0 +1,315 @@
+// Package health serves the liveness, readiness and health probes of the server.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/easy-techno-lab/proton/coder"
+	"github.com/easy-techno-lab/proton/httpserver"
+)
+
+// ErrDraining is the error of the readiness while the server is shutting down or restarting.
+var ErrDraining = errors.New("the server is draining")
+
+// Status is the status of a check or of a probe.
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// Options are the options of the Checker.
+type Options struct {
+	Formatter  httpserver.Formatter // Formatter of the reports (JSON by default).
+	CacheTTL   time.Duration        // Time during which the result of a check is reused (1s by default, negative disables).
+	DrainDelay time.Duration        // Time between failing the readiness and shutting the server down, see Attach.
+}
+
+// CheckOptions are the options of a check.
+//
+//	Timeout — time limit of the check (5s by default).
+//	Critical — if the check fails, the server is not ready, otherwise the failure is only reported.
+//	Liveness — the check is run by the liveness probe too. A failed critical liveness check means that the process
+//	has to be restarted, so only deadlocks and similar failures are checked by it, not the dependencies.
+type CheckOptions struct {
+	Timeout  time.Duration
+	Critical bool
+	Liveness bool
+}
+
+// Report is the response of a probe.
+type Report struct {
+	Status Status   `json:"status" xml:"status"`
+	Checks []Result `json:"checks,omitempty" xml:"check,omitempty"`
+}
+
+// Result is the result of a check.
+type Result struct {
+	Name      string    `json:"name" xml:"name,attr"`
+	Status    Status    `json:"status" xml:"status"`
+	Critical  bool      `json:"critical" xml:"critical"`
+	Error     string    `json:"error,omitempty" xml:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at" xml:"checked_at"`
+	Duration  string    `json:"duration" xml:"duration"`
+}
+
+// Checker runs the registered checks and serves the probes:
+//
+//	/livez — the liveness: the checks registered with Liveness.
+//	/readyz — the readiness: the critical checks, the probe fails while the server is draining.
+//	/healthz — the health: all checks.
+//
+// A probe fails if one of its critical checks fails, failed non-critical checks are only reported.
+// The probes respond with 200 OK if they pass and with 503 Service Unavailable otherwise,
+// the body is the Report encoded with the Formatter.
+type Checker struct {
+	formatter  httpserver.Formatter
+	cacheTTL   time.Duration
+	drainDelay time.Duration
+
+	mu     sync.RWMutex
+	checks []*check
+
+	draining atomic.Bool
+}
+
+type check struct {
+	name string
+	fn   func(ctx context.Context) error
+	opts CheckOptions
+
+	mu     sync.Mutex // held while the check runs, so concurrent probes reuse its result
+	result Result
+}
+
+// NewChecker returns a new Checker.
+func NewChecker(opts *Options) *Checker {
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Formatter == nil {
+		o.Formatter = httpserver.NewFormatter(coder.NewCoder("application/json", json.Marshal, json.Unmarshal, false))
+	}
+	if o.CacheTTL == 0 {
+		o.CacheTTL = time.Second
+	}
+
+	return &Checker{formatter: o.Formatter, cacheTTL: o.CacheTTL, drainDelay: o.DrainDelay}
+}
+
+// Register registers the check with the name. It panics if the name is already registered.
+// The check fails if fn returns an error, panics or does not return within the Timeout.
+func (c *Checker) Register(name string, fn func(ctx context.Context) error, opts *CheckOptions) {
+	o := CheckOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 5 * time.Second
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, ch := range c.checks {
+		if ch.name == name {
+			panic(fmt.Sprintf("health: the check %q is already registered", name))
+		}
+	}
+
+	c.checks = append(c.checks, &check{name: name, fn: fn, opts: o})
+	sort.Slice(c.checks, func(i, j int) bool { return c.checks[i].name < c.checks[j].name })
+}
+
+// SetDraining makes the readiness fail while draining is true.
+func (c *Checker) SetDraining(draining bool) {
+	c.draining.Store(draining)
+}
+
+// Attach makes the readiness of the Controller fail when a shutdown or a restart begins,
+// and pass again when the server starts serving. Then the shutdown or the restart is delayed by the DrainDelay,
+// so that load balancers stop sending requests before the connections are closed.
+// The GracefulTimeout of the shutdown starts after the DrainDelay.
+// The hooks of the Controller which are set before Attach are called after the ones of the Checker.
+func (c *Checker) Attach(hcr *httpserver.Controller) {
+	onStart, onRestart, onShutdown := hcr.OnStart, hcr.OnRestart, hcr.OnShutdown
+
+	hcr.OnStart = func(ctx context.Context) {
+		c.SetDraining(false)
+		call(ctx, onStart)
+	}
+	hcr.OnRestart = func(ctx context.Context) {
+		c.drain(ctx)
+		call(ctx, onRestart)
+	}
+	hcr.OnShutdown = func(ctx context.Context) {
+		c.drain(ctx)
+		call(ctx, onShutdown)
+	}
+}
+
+// Handler returns the http.Handler which serves the probes on /livez, /readyz and /healthz.
+func (c *Checker) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /livez", c.Livez())
+	mux.Handle("GET /readyz", c.Readyz())
+	mux.Handle("GET /healthz", c.Healthz())
+	return mux
+}
+
+// Livez returns the http.Handler of the liveness probe.
+func (c *Checker) Livez() http.Handler {
+	return c.probe(func(ch *check) bool { return ch.opts.Liveness }, false)
+}
+
+// Readyz returns the http.Handler of the readiness probe.
+func (c *Checker) Readyz() http.Handler {
+	return c.probe(func(ch *check) bool { return ch.opts.Critical }, true)
+}
+
+// Healthz returns the http.Handler of the health probe.
+func (c *Checker) Healthz() http.Handler {
+	return c.probe(func(ch *check) bool { return true }, true)
+}
+
+// probe returns the http.Handler which runs the checks chosen by the filter.
+func (c *Checker) probe(filter func(ch *check) bool, readiness bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.run(r.Context(), filter)
+
+		if readiness && c.draining.Load() {
+			report.Status = StatusDown
+			report.Checks = append(report.Checks, Result{
+				Name:      "draining",
+				Status:    StatusDown,
+				Critical:  true,
+				Error:     ErrDraining.Error(),
+				CheckedAt: time.Now(),
+			})
+		}
+
+		statusCode := http.StatusOK
+		if report.Status != StatusUp {
+			statusCode = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		c.formatter.WriteResponse(r.Context(), w, statusCode, report)
+	})
+}
+
+// run runs the checks concurrently.
+func (c *Checker) run(ctx context.Context, filter func(ch *check) bool) Report {
+	c.mu.RLock()
+	var checks []*check
+	for _, ch := range c.checks {
+		if filter(ch) {
+			checks = append(checks, ch)
+		}
+	}
+	c.mu.RUnlock()
+
+	report := Report{Status: StatusUp, Checks: make([]Result, len(checks))}
+
+	var wg sync.WaitGroup
+	for i, ch := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = ch.run(ctx, c.cacheTTL)
+		}()
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Critical && result.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+
+	return report
+}
+
+// run returns the cached result, or runs the check if the result has expired.
+func (ch *check) run(ctx context.Context, cacheTTL time.Duration) Result {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	if !ch.result.CheckedAt.IsZero() && time.Since(ch.result.CheckedAt) < cacheTTL {
+		return ch.result
+	}
+
+	// the result is shared by the probes, so it does not depend on the request being canceled
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), ch.opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := ch.call(ctx)
+
+	ch.result = Result{
+		Name:      ch.name,
+		Status:    StatusUp,
+		Critical:  ch.opts.Critical,
+		CheckedAt: start,
+		Duration:  time.Since(start).String(),
+	}
+	if err != nil {
+		ch.result.Status = StatusDown
+		ch.result.Error = err.Error()
+	}
+
+	return ch.result
+}
+
+// call calls the check in a goroutine, so that the Timeout is kept even if the check ignores ctx.
+func (ch *check) call(ctx context.Context) error {
+	errc := make(chan error, 1)
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				errc <- fmt.Errorf("panic: %v", v)
+			}
+		}()
+		errc <- ch.fn(ctx)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// drain makes the readiness fail and waits for the DrainDelay.
+func (c *Checker) drain(ctx context.Context) {
+	c.SetDraining(true)
+
+	if c.drainDelay <= 0 {
+		return
+	}
+
+	t := time.NewTimer(c.drainDelay)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
+
+func call(ctx context.Context, f func(ctx context.Context)) {
+	if f != nil {
+		f(ctx)
+	}
+}
@@ -0,0 +1,267 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/easy-techno-lab/proton/httpserver"
+	"github.com/easy-techno-lab/proton/httpserver/health"
+)
+
+func equal(t *testing.T, exp, got any) {
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("Not equal:\nexp: %v\ngot: %v", exp, got)
+	}
+}
+
+func probe(t *testing.T, h http.Handler, path string) (int, health.Report) {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var report health.Report
+	equal(t, nil, json.Unmarshal(w.Body.Bytes(), &report))
+
+	return w.Code, report
+}
+
+// statuses returns the statuses of the checks by their names.
+func statuses(report health.Report) map[string]health.Status {
+	m := make(map[string]health.Status, len(report.Checks))
+	for _, r := range report.Checks {
+		m[r.Name] = r.Status
+	}
+	return m
+}
+
+func TestChecker(t *testing.T) {
+	var dbDown atomic.Bool
+	dbDown.Store(true)
+
+	c := health.NewChecker(&health.Options{CacheTTL: -1})
+	c.Register("db", func(ctx context.Context) error {
+		if dbDown.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	}, &health.CheckOptions{Critical: true})
+	c.Register("cache", func(ctx context.Context) error {
+		return errors.New("timeout")
+	}, nil)
+	c.Register("loop", func(ctx context.Context) error {
+		return nil
+	}, &health.CheckOptions{Critical: true, Liveness: true})
+	c.Register("hung", func(ctx context.Context) error {
+		select {}
+	}, &health.CheckOptions{Timeout: 10 * time.Millisecond})
+
+	h := c.Handler()
+
+	var tests = []struct {
+		path     string
+		code     int
+		statuses map[string]health.Status
+	}{
+		{
+			path:     "/livez",
+			code:     http.StatusOK,
+			statuses: map[string]health.Status{"loop": health.StatusUp},
+		},
+		{
+			path:     "/readyz",
+			code:     http.StatusServiceUnavailable,
+			statuses: map[string]health.Status{"db": health.StatusDown, "loop": health.StatusUp},
+		},
+		{
+			path: "/healthz",
+			code: http.StatusServiceUnavailable,
+			statuses: map[string]health.Status{
+				"cache": health.StatusDown, "db": health.StatusDown, "hung": health.StatusDown, "loop": health.StatusUp,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			code, report := probe(t, h, test.path)
+			equal(t, test.code, code)
+			equal(t, test.statuses, statuses(report))
+		})
+	}
+
+	// the failed non-critical checks do not fail the probes
+	dbDown.Store(false)
+	code, report := probe(t, h, "/healthz")
+	equal(t, http.StatusOK, code)
+	equal(t, health.StatusUp, report.Status)
+	equal(t, "context deadline exceeded", report.Checks[2].Error)
+
+	c.SetDraining(true)
+	code, report = probe(t, h, "/readyz")
+	equal(t, http.StatusServiceUnavailable, code)
+	equal(t, health.StatusDown, statuses(report)["draining"])
+
+	code, _ = probe(t, h, "/livez")
+	equal(t, http.StatusOK, code)
+}
+
+func TestChecker_Cache(t *testing.T) {
+	var calls atomic.Int32
+
+	c := health.NewChecker(&health.Options{CacheTTL: time.Hour})
+	c.Register("db", func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}, &health.CheckOptions{Critical: true})
+
+	for range 3 {
+		code, _ := probe(t, c.Readyz(), "/readyz")
+		equal(t, http.StatusOK, code)
+	}
+
+	equal(t, int32(1), calls.Load())
+}
+
+func TestChecker_Attach(t *testing.T) {
+	c := health.NewChecker(&health.Options{DrainDelay: 50 * time.Millisecond})
+
+	ready := func() int {
+		code, _ := probe(t, c.Readyz(), "/readyz")
+		return code
+	}
+
+	readiness := make(chan int, 1)
+	var shutdownAt time.Time
+
+	hcr := &httpserver.Controller{
+		Server:          &http.Server{Addr: "127.0.0.1:0", Handler: c.Handler()},
+		GracefulTimeout: time.Second,
+		RestartMode:     httpserver.RestartHandoff,
+		// the hooks set before Attach are called after the readiness fails
+		OnRestart: func(ctx context.Context) {
+			readiness <- ready()
+		},
+		OnShutdown: func(ctx context.Context) {
+			shutdownAt = time.Now()
+		},
+	}
+	c.Attach(hcr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- hcr.Run(ctx) }()
+
+	<-hcr.Ready()
+	addr := hcr.Addr().String()
+
+	resp, err := http.Get("http://" + addr + "/readyz")
+	equal(t, nil, err)
+	_ = resp.Body.Close()
+	equal(t, http.StatusOK, resp.StatusCode)
+
+	hcr.Restart()
+	equal(t, http.StatusServiceUnavailable, <-readiness)
+
+	// the readiness passes when the new server starts serving
+	for i := 0; ready() != http.StatusOK; i++ {
+		if i == 100 {
+			t.Fatal("the server is not ready after the restart")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	started := time.Now()
+	cancel()
+	equal(t, nil, <-done)
+
+	equal(t, true, shutdownAt.Sub(started) >= 50*time.Millisecond)
+	equal(t, http.StatusServiceUnavailable, ready())
+}
+
+func TestChecker_AttachDrainDelay(t *testing.T) {
+	// the request is in flight when the shutdown begins and ends after the DrainDelay
+	slow := func(started chan<- struct{}, finished *atomic.Bool) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started <- struct{}{}
+			time.Sleep(350 * time.Millisecond)
+			finished.Store(true)
+		})
+	}
+
+	newController := func(h http.Handler) *httpserver.Controller {
+		hcr := &httpserver.Controller{
+			Server:          &http.Server{Addr: "127.0.0.1:0", Handler: h},
+			GracefulTimeout: 300 * time.Millisecond,
+		}
+		health.NewChecker(&health.Options{DrainDelay: 250 * time.Millisecond}).Attach(hcr)
+		return hcr
+	}
+
+	tests := []struct {
+		name string
+		run  func(ctx context.Context, h http.Handler) (addr func() string, run func() error)
+	}{
+		{
+			name: "controller",
+			run: func(ctx context.Context, h http.Handler) (func() string, func() error) {
+				hcr := newController(h)
+				return func() string { <-hcr.Ready(); return hcr.Addr().String() },
+					func() error { return hcr.Run(ctx) }
+			},
+		},
+		{
+			name: "group",
+			run: func(ctx context.Context, h http.Handler) (func() string, func() error) {
+				// the servers drain together, not one after another within the ShutdownTimeout
+				first, last := newController(http.NotFoundHandler()), newController(h)
+				g := &httpserver.Group{
+					Controllers:     []*httpserver.Controller{first, last},
+					ShutdownTimeout: 300 * time.Millisecond,
+				}
+				return func() string { <-last.Ready(); return last.Addr().String() },
+					func() error { return g.Run(ctx) }
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{}, 1)
+			var finished atomic.Bool
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			addr, run := tt.run(ctx, slow(started, &finished))
+
+			done := make(chan error, 1)
+			go func() { done <- run() }()
+
+			codes := make(chan int, 1)
+			go func() {
+				resp, err := http.Get("http://" + addr() + "/")
+				if err != nil {
+					codes <- 0
+					return
+				}
+				_ = resp.Body.Close()
+				codes <- resp.StatusCode
+			}()
+
+			<-started
+			cancel()
+			equal(t, nil, <-done)
+
+			equal(t, true, finished.Load())
+			equal(t, http.StatusOK, <-codes)
+		})
+	}
+}
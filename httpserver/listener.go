@@ -0,0 +1,520 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ListenFunc creates the listener for the Addr of the server. It is called when the server starts,
+// and on every restart that needs a new listener.
+type ListenFunc func(ctx context.Context, addr string) (net.Listener, error)
+
+// The environment variables used by RestartExec to pass the listeners to the new process.
+//
+//	ListenFDsEnv — listeners as a semicolon-separated list of address=fd pairs, for example ":8080=3".
+//	ReadyFDEnv — fd of a pipe, the new process writes to it when its listeners are bound.
+const (
+	ListenFDsEnv = "PROTON_LISTEN_FDS"
+	ReadyFDEnv   = "PROTON_READY_FD"
+)
+
+const execReadyTimeout = 30 * time.Second
+
+var (
+	ErrListenerNotFile = errors.New("the listener cannot be passed to another server")
+	ErrNoSystemdSocket = errors.New("no socket is passed by systemd for the address")
+)
+
+// filer is implemented by *net.TCPListener, *net.UnixListener, keepAliveListener and sharedUnixListener.
+type filer interface {
+	File() (*os.File, error)
+}
+
+var inherited struct {
+	once sync.Once
+	fds  map[string]int
+}
+
+// TCPOptions are the options of the TCP listener.
+//
+//	ReusePort — sets SO_REUSEPORT, so that several processes can listen on the same port and share the connections.
+//	KeepAlive — idle time of a connection before the first keep-alive probe (15s by default).
+//	KeepAliveInterval — time between the keep-alive probes (KeepAlive by default).
+//	KeepAliveCount — number of unanswered keep-alive probes after which the connection is dropped
+//	(the default of the system).
+//
+// The options are supported on Linux. The keep-alive options are kept on the listening socket,
+// so they are applied to the connections after a restart with RestartHandoff or RestartExec too.
+type TCPOptions struct {
+	ReusePort         bool
+	KeepAlive         time.Duration
+	KeepAliveInterval time.Duration
+	KeepAliveCount    int
+}
+
+// keepAlive is the keep-alive options of the connections accepted on a listener.
+type keepAlive struct {
+	idle     time.Duration
+	interval time.Duration
+	count    int
+}
+
+// ListenTCP returns the ListenFunc that listens on the TCP address, ":http" if it is empty.
+// It is used by Controller by default.
+func ListenTCP(opts *TCPOptions) ListenFunc {
+	o := TCPOptions{}
+	if opts != nil {
+		o = *opts
+	}
+
+	tuned := o.KeepAlive > 0 || o.KeepAliveInterval > 0 || o.KeepAliveCount > 0
+	if tuned {
+		if o.KeepAlive <= 0 {
+			o.KeepAlive = 15 * time.Second
+		}
+		if o.KeepAliveInterval <= 0 {
+			o.KeepAliveInterval = o.KeepAlive
+		}
+	}
+	ka := keepAlive{idle: o.KeepAlive, interval: o.KeepAliveInterval, count: o.KeepAliveCount}
+
+	lc := &net.ListenConfig{
+		Control: func(_, _ string, c syscall.RawConn) error {
+			return control(c, func(fd uintptr) error {
+				if o.ReusePort {
+					if err := setReusePort(fd); err != nil {
+						return err
+					}
+				}
+				if tuned {
+					return setKeepAlive(fd, ka)
+				}
+				return nil
+			})
+		},
+	}
+
+	return func(ctx context.Context, addr string) (net.Listener, error) {
+		if addr == "" {
+			addr = ":http"
+		}
+
+		ln, err := lc.Listen(ctx, "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+
+		return keepAliveOf(ln), nil
+	}
+}
+
+// UnixOptions are the options of the unix socket listener.
+//
+//	Mode — permissions of the socket file (set by umask by default).
+//	User — owner of the socket file, a name or a numeric id (not changed by default).
+//	Group — group of the socket file, a name or a numeric id (not changed by default).
+type UnixOptions struct {
+	Mode  os.FileMode
+	User  string
+	Group string
+}
+
+// ListenUnix returns the ListenFunc that listens on the unix socket, the Addr of the server is the path of the socket.
+// A socket file left by a stopped process is removed. The socket file is removed when the server is shut down,
+// except after a restart with RestartExec, then it is left for the new process.
+func ListenUnix(opts *UnixOptions) ListenFunc {
+	o := UnixOptions{}
+	if opts != nil {
+		o = *opts
+	}
+
+	return func(ctx context.Context, addr string) (net.Listener, error) {
+		if err := removeStaleSocket(ctx, addr); err != nil {
+			return nil, err
+		}
+
+		var lc net.ListenConfig
+		ln, err := lc.Listen(ctx, "unix", addr)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = chownSocket(addr, o); err != nil {
+			_ = ln.Close()
+			return nil, err
+		}
+
+		return ln, nil
+	}
+}
+
+// removeStaleSocket removes the socket file if no one accepts on it.
+func removeStaleSocket(ctx context.Context, path string) error {
+	if path == "" || path[0] == '@' {
+		return nil // abstract sockets have no files
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return nil // the error of listen is more descriptive
+	}
+
+	var d net.Dialer
+	if conn, err := d.DialContext(ctx, "unix", path); err == nil {
+		_ = conn.Close()
+		return nil
+	}
+
+	return os.Remove(path)
+}
+
+func chownSocket(path string, o UnixOptions) error {
+	if o.User != "" || o.Group != "" {
+		uid, gid := -1, -1
+
+		if o.User != "" {
+			u, err := user.Lookup(o.User)
+			if err != nil {
+				if u, err = user.LookupId(o.User); err != nil {
+					return err
+				}
+			}
+			if uid, err = strconv.Atoi(u.Uid); err != nil {
+				return err
+			}
+		}
+
+		if o.Group != "" {
+			g, err := user.LookupGroup(o.Group)
+			if err != nil {
+				if g, err = user.LookupGroupId(o.Group); err != nil {
+					return err
+				}
+			}
+			if gid, err = strconv.Atoi(g.Gid); err != nil {
+				return err
+			}
+		}
+
+		if err := os.Chown(path, uid, gid); err != nil {
+			return err
+		}
+	}
+
+	if o.Mode != 0 {
+		return os.Chmod(path, o.Mode)
+	}
+
+	return nil
+}
+
+var activated struct {
+	once  sync.Once
+	files []*os.File
+	names map[string]*os.File
+}
+
+// ListenSystemd is the ListenFunc that returns the socket passed by systemd with socket activation (LISTEN_FDS).
+// The socket is found by its name (FileDescriptorName= of the socket unit) equal to the Addr of the server,
+// or it is the only passed socket. The socket is kept open, so the server can be restarted in any RestartMode.
+func ListenSystemd(_ context.Context, addr string) (net.Listener, error) {
+	activated.once.Do(func() {
+		activated.names = make(map[string]*os.File)
+
+		pid, fds, names := os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES")
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+
+		if pid != strconv.Itoa(os.Getpid()) {
+			return
+		}
+
+		n, err := strconv.Atoi(fds)
+		if err != nil {
+			return
+		}
+
+		nameList := strings.Split(names, ":")
+		for i := range n {
+			// the sockets are passed starting from fd 3
+			fd := 3 + i
+			closeOnExec(fd)
+
+			name := "LISTEN_FD_" + strconv.Itoa(fd)
+			if i < len(nameList) && nameList[i] != "" {
+				name = nameList[i]
+			}
+			f := os.NewFile(uintptr(fd), name)
+			activated.files = append(activated.files, f)
+			activated.names[name] = f
+		}
+	})
+
+	f, ok := activated.names[addr]
+	if !ok && len(activated.files) == 1 {
+		f, ok = activated.files[0], true
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrNoSystemdSocket, addr)
+	}
+
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("systemd socket %s: %w", f.Name(), err)
+	}
+
+	return fileListenerOf(ln), nil
+}
+
+// keepAliveListener sets the keep-alive options of the listening socket to the accepted connections,
+// otherwise they are replaced by the defaults of Go.
+type keepAliveListener struct {
+	*net.TCPListener
+	keepAlive
+}
+
+func (ln *keepAliveListener) Accept() (net.Conn, error) {
+	conn, err := ln.AcceptTCP()
+	if err != nil {
+		return nil, err
+	}
+
+	if c, err := conn.SyscallConn(); err == nil {
+		_ = control(c, func(fd uintptr) error { return setKeepAlive(fd, ln.keepAlive) })
+	}
+
+	return conn, nil
+}
+
+// keepAliveOf wraps the TCP listener with keepAliveListener if keep-alive is enabled on the listening socket.
+func keepAliveOf(ln net.Listener) net.Listener {
+	tl, ok := ln.(*net.TCPListener)
+	if !ok {
+		return ln
+	}
+
+	c, err := tl.SyscallConn()
+	if err != nil {
+		return ln
+	}
+
+	var ka keepAlive
+	var enabled bool
+	_ = c.Control(func(fd uintptr) { ka, enabled = getKeepAlive(fd) })
+	if !enabled {
+		return ln
+	}
+
+	return &keepAliveListener{TCPListener: tl, keepAlive: ka}
+}
+
+// sharedUnixListener is a unix listener created from a file, its socket file is not removed when it is closed,
+// since the file belongs to whoever created the socket: systemd, the parent process or another listener.
+type sharedUnixListener struct {
+	*net.UnixListener
+}
+
+// fileListenerOf wraps the listener created from a file with keepAliveListener or sharedUnixListener.
+func fileListenerOf(ln net.Listener) net.Listener {
+	if ul, ok := ln.(*net.UnixListener); ok {
+		return &sharedUnixListener{UnixListener: ul}
+	}
+	return keepAliveOf(ln)
+}
+
+func control(c syscall.RawConn, f func(fd uintptr) error) error {
+	var err error
+	if cerr := c.Control(func(fd uintptr) { err = f(fd) }); cerr != nil {
+		return cerr
+	}
+	return err
+}
+
+// inheritedListener returns the listener passed by the parent process with RestartExec, every listener is used once.
+func inheritedListener(addr string) (net.Listener, error) {
+	inherited.once.Do(func() {
+		inherited.fds = make(map[string]int)
+
+		value := os.Getenv(ListenFDsEnv)
+		_ = os.Unsetenv(ListenFDsEnv)
+
+		for _, pair := range strings.Split(value, ";") {
+			i := strings.LastIndexByte(pair, '=')
+			if i < 0 {
+				continue
+			}
+			if fd, err := strconv.Atoi(pair[i+1:]); err == nil {
+				inherited.fds[pair[:i]] = fd
+			}
+		}
+	})
+
+	fd, ok := inherited.fds[addr]
+	if !ok {
+		return nil, nil
+	}
+	delete(inherited.fds, addr)
+
+	f := os.NewFile(uintptr(fd), addr)
+	defer func() { _ = f.Close() }()
+
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("inherited listener %s: %w", addr, err)
+	}
+
+	return fileListenerOf(ln), nil
+}
+
+// dupListener returns a new listener on the same socket, so that the listeners can be closed independently.
+func dupListener(ln net.Listener) (net.Listener, error) {
+	fl, ok := ln.(filer)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrListenerNotFile, ln)
+	}
+
+	f, err := fl.File()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	dup, err := net.FileListener(f)
+	if err != nil {
+		return nil, err
+	}
+
+	if ul, ok := ln.(*net.UnixListener); ok {
+		// the socket file is handed over to the duplicate, which is closed after the listener
+		ul.SetUnlinkOnClose(false)
+		if dul, ok := dup.(*net.UnixListener); ok {
+			dul.SetUnlinkOnClose(true)
+			return dul, nil
+		}
+	}
+
+	return fileListenerOf(dup), nil
+}
+
+// keepSocketFile prevents the unix socket file from being removed when the listener is closed,
+// since the socket is still used by another listener.
+func keepSocketFile(ln net.Listener) {
+	if ul, ok := ln.(*net.UnixListener); ok {
+		ul.SetUnlinkOnClose(false)
+	}
+}
+
+// restoreNonblock puts the listeners back into non-blocking mode. The files passed to the new process are switched
+// to blocking mode, which the listeners share with them, and a blocking Accept would prevent them from being closed
+// if the server keeps serving after a failed restart.
+func restoreNonblock(listeners map[string]net.Listener) {
+	for _, ln := range listeners {
+		if sc, ok := ln.(syscall.Conn); ok {
+			if c, err := sc.SyscallConn(); err == nil {
+				_ = control(c, setNonblock)
+			}
+		}
+	}
+}
+
+// notifyReady tells the parent process that the listeners are bound, so that it can shut down.
+func notifyReady() {
+	value := os.Getenv(ReadyFDEnv)
+	if value == "" {
+		return
+	}
+	_ = os.Unsetenv(ReadyFDEnv)
+
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return
+	}
+
+	f := os.NewFile(uintptr(fd), "ready")
+	_, _ = f.Write([]byte{1})
+	_ = f.Close()
+}
+
+// execWithListeners starts the executable of the process again with the listeners by their Addr
+// and waits until it binds them.
+func execWithListeners(listeners map[string]net.Listener) error {
+	path, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	var fds []string
+	for addr, ln := range listeners {
+		fl, ok := ln.(filer)
+		if !ok {
+			return fmt.Errorf("%w: %T", ErrListenerNotFile, ln)
+		}
+
+		var f *os.File
+		if f, err = fl.File(); err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+
+		// ExtraFiles[i] becomes fd 3+i in the new process
+		fds = append(fds, addr+"="+strconv.Itoa(3+len(cmd.ExtraFiles)))
+		cmd.ExtraFiles = append(cmd.ExtraFiles, f)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+
+	cmd.Env = append(os.Environ(),
+		ListenFDsEnv+"="+strings.Join(fds, ";"),
+		ReadyFDEnv+"="+strconv.Itoa(3+len(cmd.ExtraFiles)),
+	)
+	cmd.ExtraFiles = append(cmd.ExtraFiles, w)
+
+	err = cmd.Start()
+	_ = w.Close()
+	restoreNonblock(listeners)
+	if err != nil {
+		return err
+	}
+
+	ready := make(chan bool, 1)
+	go func() {
+		n, _ := r.Read(make([]byte, 1))
+		ready <- n == 1
+	}()
+
+	select {
+	case ok := <-ready:
+		if ok {
+			// the socket files are kept only once the new process serves them
+			for _, ln := range listeners {
+				keepSocketFile(ln)
+			}
+			_ = cmd.Process.Release()
+			return nil
+		}
+	case <-time.After(execReadyTimeout):
+	}
+
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+
+	return fmt.Errorf("the new process %d is not ready", cmd.Process.Pid)
+}
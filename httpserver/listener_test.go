@@ -0,0 +1,237 @@
+package httpserver_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/easy-techno-lab/proton/httpserver"
+)
+
+// unixGet requests the server listening on the unix socket, it returns an empty string if the socket refuses.
+func unixGet(t *testing.T, path string) string {
+	clt := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+	defer clt.CloseIdleConnections()
+
+	resp, err := clt.Get("http://unix/")
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	equal(t, nil, err)
+
+	return string(body)
+}
+
+func TestListenUnix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are tested on unix")
+	}
+
+	for _, mode := range []httpserver.RestartMode{httpserver.RestartShutdown, httpserver.RestartHandoff} {
+		t.Run(mode.String(), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "server.sock")
+
+			// a socket file left by a stopped process
+			stale, err := net.Listen("unix", path)
+			equal(t, nil, err)
+			stale.(*net.UnixListener).SetUnlinkOnClose(false)
+			equal(t, nil, stale.Close())
+
+			hcr := &httpserver.Controller{
+				Server:          &http.Server{Addr: path, Handler: text("old", nil, nil)},
+				GracefulTimeout: time.Second,
+				Listen:          httpserver.ListenUnix(&httpserver.UnixOptions{Mode: 0o600}),
+				RestartMode:     mode,
+			}
+
+			done := start(t, hcr)
+			equal(t, "old", unixGet(t, path))
+
+			fi, err := os.Stat(path)
+			equal(t, nil, err)
+			equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+			hcr.Server.Handler = text("new", nil, nil)
+			hcr.Restart()
+
+			for i := 0; unixGet(t, path) != "new"; i++ {
+				if i == 100 {
+					t.Fatal("the server is not restarted")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			hcr.Shutdown()
+			equal(t, nil, <-done)
+
+			// the socket file is removed by the last listener
+			_, err = os.Stat(path)
+			equal(t, true, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestListenUnix_ExecFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are tested on unix")
+	}
+
+	path := filepath.Join(t.TempDir(), "server.sock")
+
+	started := make(chan struct{}, 2)
+
+	hcr := &httpserver.Controller{
+		Server:          &http.Server{Addr: path, Handler: text("ok", nil, nil)},
+		GracefulTimeout: time.Second,
+		Listen:          httpserver.ListenUnix(nil),
+		RestartMode:     httpserver.RestartExec,
+		OnStart:         func(context.Context) { started <- struct{}{} },
+	}
+
+	done := start(t, hcr)
+	<-started
+
+	args, stdout, stderr := os.Args, os.Stdout, os.Stderr
+	defer func() { os.Args, os.Stdout, os.Stderr = args, stdout, stderr }()
+
+	devNull, err := os.Open(os.DevNull)
+	equal(t, nil, err)
+	defer func() { _ = devNull.Close() }()
+
+	// the new process runs no tests and exits without serving the socket
+	os.Args = []string{args[0], "-test.run=^$"}
+	os.Stdout, os.Stderr = devNull, devNull
+
+	hcr.Restart()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the restart has not failed")
+	}
+
+	// the old server keeps serving and owns the socket file
+	equal(t, "ok", unixGet(t, path))
+
+	hcr.Shutdown()
+	equal(t, nil, <-done)
+
+	_, err = os.Stat(path)
+	equal(t, true, os.IsNotExist(err))
+}
+
+func TestListenTCP(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the options are supported on Linux")
+	}
+
+	listen := httpserver.ListenTCP(&httpserver.TCPOptions{
+		ReusePort: true,
+		KeepAlive: time.Minute,
+	})
+
+	ln, err := listen(context.Background(), "127.0.0.1:0")
+	equal(t, nil, err)
+	defer func() { _ = ln.Close() }()
+
+	// the port is shared
+	ln2, err := listen(context.Background(), ln.Addr().String())
+	equal(t, nil, err)
+	equal(t, nil, ln2.Close())
+
+	// the options are kept after a restart with RestartHandoff
+	hcr := &httpserver.Controller{
+		Server:      &http.Server{Addr: "127.0.0.1:0", Handler: text("ok", nil, nil)},
+		Listen:      listen,
+		RestartMode: httpserver.RestartHandoff,
+	}
+
+	done := start(t, hcr)
+	addr := hcr.Addr().String()
+	equal(t, "ok", get(t, "http://"+addr))
+
+	hcr.Restart()
+	for i := 0; get(t, "http://"+addr) != "ok"; i++ {
+		if i == 100 {
+			t.Fatal("the server is not restarted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	hcr.Shutdown()
+	equal(t, nil, <-done)
+
+	_, err = httpserver.ListenTCP(&httpserver.TCPOptions{KeepAliveCount: -1})(context.Background(), "127.0.0.1:0")
+	equal(t, nil, err)
+}
+
+func TestListenSystemd(t *testing.T) {
+	// the process activated by the test with the socket
+	if os.Getenv("LISTEN_FDS") != "" {
+		hcr := &httpserver.Controller{
+			Server: &http.Server{Addr: "web"},
+			Listen: httpserver.ListenSystemd,
+		}
+		hcr.Server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/restart":
+				hcr.Restart()
+			case "/exit":
+				go hcr.Shutdown()
+			}
+			_, _ = io.WriteString(w, "activated")
+		})
+		if err := hcr.Start(); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if runtime.GOOS != "linux" {
+		t.Skip("socket activation is tested on Linux")
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	equal(t, nil, err)
+	addr := ln.Addr().String()
+
+	f, err := ln.(*net.TCPListener).File()
+	equal(t, nil, err)
+	equal(t, nil, ln.Close())
+
+	// LISTEN_PID is the pid of the activated process, the shell is replaced with it
+	cmd := exec.Command("/bin/sh", "-c", `LISTEN_PID=$$ exec "$0" -test.run=^TestListenSystemd$`, os.Args[0])
+	cmd.Env = append(os.Environ(), "LISTEN_FDS=1", "LISTEN_FDNAMES=web")
+	cmd.ExtraFiles = []*os.File{f}
+	equal(t, nil, cmd.Start())
+	equal(t, nil, f.Close())
+
+	// the socket is listening before the process starts serving
+	equal(t, "activated", get(t, "http://"+addr+"/restart"))
+
+	// the socket is kept open by the process while the server restarts
+	for i := 0; get(t, "http://"+addr) != "activated"; i++ {
+		if i == 100 {
+			t.Fatal("the server is not restarted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	equal(t, "activated", get(t, "http://"+addr+"/exit"))
+	equal(t, nil, cmd.Wait())
+}
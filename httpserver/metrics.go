@@ -0,0 +1,63 @@
+package httpserver
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/easy-techno-lab/proton/metrics"
+)
+
+// Metrics records the metrics of the served requests in the Registry:
+//
+//	http_server_requests_total — counter of requests by method, route and status.
+//	http_server_request_duration_seconds — histogram of request durations by method, route and status.
+//	http_server_response_size_bytes — histogram of response body sizes by method, route and status.
+//	http_server_requests_in_flight — gauge of requests being served by method.
+//
+// The route is the pattern of the Router route that matched the request, it is empty for other requests.
+// Unknown methods are recorded as OTHER, so that the number of series stays bounded.
+func Metrics(reg *metrics.Registry) func(http.Handler) http.Handler {
+	labels := []string{"method", "route", "status"}
+
+	requests := reg.Counter("http_server_requests_total",
+		"Total number of HTTP requests served.", labels...)
+	duration := reg.Histogram("http_server_request_duration_seconds",
+		"Duration of HTTP requests in seconds.", metrics.DefBuckets, labels...)
+	size := reg.Histogram("http_server_response_size_bytes",
+		"Size of HTTP response bodies in bytes.", metrics.ExponentialBuckets(100, 10, 7), labels...)
+	inFlight := reg.Gauge("http_server_requests_in_flight",
+		"Number of HTTP requests being served.", "method")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			method := metrics.Method(r.Method)
+
+			g := inFlight.With(method)
+			g.Inc()
+			defer g.Dec()
+
+			r, info := withRouteInfo(r)
+			rw := NewResponseWriter(w)
+
+			defer func() {
+				values := []string{method, metricRoute(info.pattern), strconv.Itoa(rw.Status())}
+				requests.With(values...).Inc()
+				duration.With(values...).Observe(time.Since(start).Seconds())
+				size.With(values...).Observe(float64(rw.BytesWritten()))
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// metricRoute returns the route pattern without the method.
+func metricRoute(pattern string) string {
+	if i := strings.IndexByte(pattern, ' '); i >= 0 {
+		return strings.TrimLeft(pattern[i+1:], " \t")
+	}
+	return pattern
+}
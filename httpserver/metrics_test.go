@@ -0,0 +1,43 @@
+package httpserver_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/easy-techno-lab/proton/httpserver"
+	"github.com/easy-techno-lab/proton/metrics"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+
+	router := httpserver.NewRouter()
+	router.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("user"))
+	})
+
+	handler := httpserver.MiddlewareSequencer(router, httpserver.Metrics(reg))
+
+	for _, path := range []string{"/users/1", "/users/2", "/unknown"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("CUSTOM", "/users/1", nil))
+
+	buf := new(bytes.Buffer)
+	_, err := reg.WriteTo(buf)
+	equal(t, nil, err)
+
+	for _, line := range []string{
+		`http_server_requests_total{method="GET",route="/users/{id}",status="200"} 2`,
+		`http_server_requests_total{method="GET",route="",status="404"} 1`,
+		`http_server_requests_total{method="OTHER",route="",status="405"} 1`,
+		`http_server_request_duration_seconds_count{method="GET",route="/users/{id}",status="200"} 2`,
+		`http_server_response_size_bytes_sum{method="GET",route="/users/{id}",status="200"} 8`,
+		`http_server_requests_in_flight{method="GET"} 0`,
+	} {
+		equal(t, true, strings.Contains(buf.String(), line+"\n"))
+	}
+}
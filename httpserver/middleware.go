@@ -4,10 +4,13 @@ import (
 	"context"
 	"log/slog"
 	"net/http"
-	"net/http/httptest"
 	"runtime/debug"
+	"strings"
 	"time"
 
+	"github.com/easy-techno-lab/proton/coder"
+	"github.com/easy-techno-lab/proton/problem"
+	"github.com/easy-techno-lab/proton/trace"
 	"github.com/easy-techno-lab/proton/utils/log"
 	"github.com/easy-techno-lab/proton/utils/sgen"
 )
@@ -18,6 +21,20 @@ func init() {
 
 var id sgen.RandomString
 
+type contextKey int
+
+const (
+	negotiatedCtxKey contextKey = iota + 1
+	routeCtxKey
+	subjectCtxKey
+	claimsCtxKey
+)
+
+// negotiated holds the Coders chosen by the Negotiate middleware.
+type negotiated struct {
+	request, response coder.Coder
+}
+
 // MiddlewareSequencer chains middleware functions in a chain.
 func MiddlewareSequencer(baseHandler http.Handler, mws ...func(http.Handler) http.Handler) http.Handler {
 	for _, f := range mws {
@@ -26,32 +43,123 @@ func MiddlewareSequencer(baseHandler http.Handler, mws ...func(http.Handler) htt
 	return baseHandler
 }
 
-// Tracer adds trace ID to the request context.
+// Tracer starts a server span for the request, see trace.Start.
+// If the request has a valid traceparent header, the span continues the remote trace,
+// otherwise a new trace is started. The trace and span IDs are available to log.TraceHandler.
+// The span is named after the route pattern when the request is served by a Router.
 func Tracer(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx := context.WithValue(r.Context(), log.TraceCtxKey, id.Generate())
-		next.ServeHTTP(w, r.Clone(ctx))
+		ctx := r.Context()
+		if sc, ok := trace.Extract(r.Header); ok {
+			ctx = trace.ContextWithSpanContext(ctx, sc)
+		}
+
+		ctx, span := trace.Start(ctx, r.Method, trace.WithKind(trace.KindServer), trace.WithAttributes(
+			slog.String("http.request.method", r.Method),
+			slog.String("url.path", r.URL.Path),
+		))
+		defer span.End()
+
+		r, info := withRouteInfo(r.Clone(ctx))
+		rw := NewResponseWriter(w)
+
+		next.ServeHTTP(rw, r)
+
+		if info.pattern != "" {
+			span.SetName(spanName(r.Method, info.pattern))
+			span.SetAttributes(slog.String("http.route", info.pattern))
+		}
+
+		statusCode := rw.Status()
+		span.SetAttributes(slog.Int("http.response.status_code", statusCode))
+		if statusCode >= http.StatusInternalServerError {
+			span.SetStatus(trace.StatusError, http.StatusText(statusCode))
+		} else if err := rw.Err(); err != nil {
+			span.RecordError(err)
+		}
 	})
 }
 
-// Timer measures the time taken by http.HandlerFunc.
-func Timer(level slog.Level) func(http.Handler) http.Handler {
+// spanName returns the name of the server span, the method is added if the pattern does not have it.
+func spanName(method, pattern string) string {
+	if strings.Contains(pattern, " ") {
+		return pattern
+	}
+	return method + " " + pattern
+}
+
+// RequestID adds the request ID from the header to the request context and the response headers.
+// If the request does not have the header, a random ID is generated.
+// The request ID is available to log.TraceHandler.
+func RequestID(header string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(header)
+			if requestID == "" {
+				requestID = id.Generate()
+			}
+
+			w.Header().Set(header, requestID)
+
+			ctx := context.WithValue(r.Context(), log.RequestIDCtxKey, requestID)
+			next.ServeHTTP(w, r.Clone(ctx))
+		})
+	}
+}
+
+// ContextWithSubject returns a copy of ctx with the authenticated subject, for example a user ID.
+// It is used by authentication middleware, so that KeyBySubject can rate limit the subject.
+func ContextWithSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subjectCtxKey, subject)
+}
+
+// SubjectFromContext returns the authenticated subject stored in ctx.
+func SubjectFromContext(ctx context.Context) (string, bool) {
+	subject, ok := ctx.Value(subjectCtxKey).(string)
+	return subject, ok && subject != ""
+}
+
+// Timer measures the time taken by http.HandlerFunc and logs it with the status code and the response size.
+// If the request context has no span, Timer starts a server span like Tracer.
+func Timer(level slog.Level) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		timed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
 			if slog.Default().Enabled(ctx, level) {
+				rw := NewResponseWriter(w)
 				defer func(start time.Time) {
-					slog.Log(ctx, level, "finished",
+					attrs := []any{
 						slog.Group("request",
 							slog.String("method", r.Method),
 							slog.String("url", r.RequestURI),
 						),
+						slog.Group("response",
+							slog.Int("status", rw.Status()),
+							slog.Int64("bytes", rw.BytesWritten()),
+						),
 						slog.String("duration", time.Since(start).String()),
-					)
+					}
+					if !rw.FirstByte().IsZero() {
+						attrs = append(attrs, slog.String("first_byte", rw.FirstByte().Sub(start).String()))
+					}
+					if err := rw.Err(); err != nil {
+						attrs = append(attrs, slog.Any("error", err))
+					}
+					slog.Log(ctx, level, "finished", attrs...)
 				}(time.Now())
+				w = rw
 			}
 			next.ServeHTTP(w, r)
 		})
+		traced := Tracer(timed)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if trace.SpanFromContext(r.Context()) == nil {
+				traced.ServeHTTP(w, r)
+				return
+			}
+			timed.ServeHTTP(w, r)
+		})
 	}
 }
 
@@ -70,31 +178,47 @@ func PanicCatcher(next http.Handler) http.Handler {
 	})
 }
 
-// DumpHttp dumps the HTTP request and response, and prints out.
+// DumpHttp dumps the HTTP request and response with the default log.Dumper, and prints out.
 func DumpHttp(level slog.Level) func(http.Handler) http.Handler {
+	return DumpHttpWith(level, nil)
+}
+
+// DumpHttpWith dumps the HTTP request and response with the log.Dumper, and prints out.
+// The bodies are captured while the handler reads the request and streams the response,
+// so both are printed after the handler returns. If the dumper is nil, the default one is used.
+func DumpHttpWith(level slog.Level, dumper *log.Dumper) func(http.Handler) http.Handler {
+	if dumper == nil {
+		dumper = new(log.Dumper)
+	}
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
 			if slog.Default().Enabled(ctx, level) {
-				log.DumpHttpRequest(ctx, r, level)
+				requestBody := dumper.Capture()
+				if r.Body != nil && r.Body != http.NoBody {
+					r = r.WithContext(ctx)
+					r.Body = requestBody.Tee(r.Body)
+				}
 
-				recorder := httptest.NewRecorder()
+				responseBody := dumper.Capture()
+				rw := NewResponseWriter(w)
+				rw.Tee(responseBody)
 
-				next.ServeHTTP(recorder, r)
+				next.ServeHTTP(rw, r)
 
-				for key, values := range recorder.Header() {
-					w.Header().Del(key)
-					for _, value := range values {
-						w.Header().Set(key, value)
-					}
-				}
+				dumper.Request(ctx, r, requestBody, level)
 
-				w.WriteHeader(recorder.Code)
+				if rw.Status() == 0 {
+					return // hijacked
+				}
 
-				response := recorder.Result()
-				response.ContentLength, _ = recorder.Body.WriteTo(w)
+				response := &http.Response{
+					StatusCode: rw.Status(),
+					Proto:      r.Proto,
+					Header:     rw.Header(),
+				}
 
-				log.DumpHttpResponse(ctx, response, level)
+				dumper.Response(ctx, response, responseBody, level)
 
 				return
 			}
@@ -102,3 +226,33 @@ func DumpHttp(level slog.Level) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// Negotiate chooses the request Coder by the Content-Type header and the response Coder by the Accept header
+// from the Registry, and stores them in the request context for the Formatter returned by NewRegistryFormatter.
+// If the media type of the request body is not supported, it responds with 415 Unsupported Media Type.
+// If none of the Coders is acceptable, it responds with 406 Not Acceptable.
+func Negotiate(registry *coder.Registry) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := &negotiated{request: registry.Default()}
+
+			var err error
+			if r.ContentLength != 0 {
+				if n.request, err = registry.Lookup(r.Header.Get(coder.ContentType)); err != nil {
+					NewFormatter(registry.Default()).WriteError(r.Context(), w, problem.Wrap(http.StatusUnsupportedMediaType, err))
+					return
+				}
+			}
+
+			if n.response, err = registry.Negotiate(r.Header.Get("Accept")); err != nil {
+				NewFormatter(registry.Default()).WriteError(r.Context(), w, problem.Wrap(http.StatusNotAcceptable, err))
+				return
+			}
+
+			w.Header().Add("Vary", "Accept")
+
+			ctx := context.WithValue(r.Context(), negotiatedCtxKey, n)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !unix
+
+package httpserver
+
+func setNonblock(uintptr) error {
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build unix
+
+package httpserver
+
+import (
+	"os"
+	"syscall"
+)
+
+func setNonblock(fd uintptr) error {
+	return os.NewSyscallError("setnonblock", syscall.SetNonblock(int(fd), true))
+}
@@ -0,0 +1,68 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+)
+
+// Param is the set of types a request parameter can be parsed into.
+type Param interface {
+	~string | ~bool |
+		~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 |
+		~float32 | ~float64
+}
+
+// PathParam returns the value of the path wildcard parsed into T.
+// If the wildcard is empty, ErrParamMissing is returned.
+func PathParam[T Param](r *http.Request, name string) (T, error) {
+	var v T
+
+	s := r.PathValue(name)
+	if s == "" {
+		return v, fmt.Errorf("path parameter %q: %w", name, ErrParamMissing)
+	}
+
+	if err := parseParam(reflect.ValueOf(&v).Elem(), s); err != nil {
+		return v, fmt.Errorf("path parameter %q: %w", name, err)
+	}
+
+	return v, nil
+}
+
+// parseParam parses s into v according to the kind of v.
+func parseParam(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported parameter type %s", v.Type())
+	}
+	return nil
+}
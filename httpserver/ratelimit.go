@@ -0,0 +1,216 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/easy-techno-lab/proton/coder"
+	"github.com/easy-techno-lab/proton/problem"
+)
+
+var ErrInvalidLimit = errors.New("the rate limit must have positive Requests and Window")
+
+// RateLimitAlgorithm is the algorithm used to count requests.
+type RateLimitAlgorithm int
+
+const (
+	TokenBucket   RateLimitAlgorithm = iota // Tokens are refilled continuously, bursts up to Burst are allowed.
+	SlidingWindow                           // Requests are counted in a window that slides with time.
+)
+
+// Limit is the number of requests allowed in the window.
+//
+//	Algorithm — algorithm used to count requests (TokenBucket by default).
+//	Requests — number of requests allowed in the Window.
+//	Window — duration of the window.
+//	Burst — capacity of the token bucket (Requests by default), it is not used by SlidingWindow.
+type Limit struct {
+	Algorithm RateLimitAlgorithm
+	Requests  int
+	Window    time.Duration
+	Burst     int
+}
+
+func (l Limit) burst() int {
+	if l.Algorithm == TokenBucket && l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// Decision is the result of counting a request.
+//
+//	Allowed — the request is within the limit.
+//	Limit — maximum number of requests, the capacity of the token bucket.
+//	Remaining — number of requests that are allowed right now.
+//	Reset — time until the limit is fully restored.
+//	RetryAfter — time until the next request is allowed, zero if the request is allowed.
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Store counts requests by keys. It must be safe for concurrent use.
+type Store interface {
+	// Allow counts the request with the key and decides whether it is within the limit.
+	Allow(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+// KeyFunc returns the key the request is rate limited by.
+// Requests with an empty key are not rate limited.
+type KeyFunc func(r *http.Request) string
+
+// KeyByIP returns a KeyFunc which limits requests by the client IP address, see TrustedProxies.ClientIP.
+func KeyByIP(proxies TrustedProxies) KeyFunc {
+	return func(r *http.Request) string {
+		return "ip:" + proxies.ClientIP(r)
+	}
+}
+
+// KeyByHeader returns a KeyFunc which limits requests by the value of the header.
+func KeyByHeader(name string) KeyFunc {
+	return func(r *http.Request) string {
+		if value := r.Header.Get(name); value != "" {
+			return "header:" + value
+		}
+		return ""
+	}
+}
+
+// KeyByAPIKey returns a KeyFunc which limits requests by the API key from the header or the query parameter.
+// The key is hashed, so that API keys are not kept in the Store.
+func KeyByAPIKey(header, query string) KeyFunc {
+	return func(r *http.Request) string {
+		var value string
+		if header != "" {
+			value = r.Header.Get(header)
+		}
+		if value == "" && query != "" {
+			value = r.URL.Query().Get(query)
+		}
+		if value == "" {
+			return ""
+		}
+		sum := sha256.Sum256([]byte(value))
+		return "apikey:" + hex.EncodeToString(sum[:16])
+	}
+}
+
+// KeyBySubject returns a KeyFunc which limits requests by the authenticated subject, see ContextWithSubject.
+func KeyBySubject() KeyFunc {
+	return func(r *http.Request) string {
+		if subject, ok := SubjectFromContext(r.Context()); ok {
+			return "subject:" + subject
+		}
+		return ""
+	}
+}
+
+// KeyByRoute returns a KeyFunc which limits requests by the Router route pattern.
+// The pattern is known only inside the Router, so RateLimit must be added with Router.Use or Router.Group.
+func KeyByRoute() KeyFunc {
+	return func(r *http.Request) string {
+		if pattern := RoutePattern(r); pattern != "" {
+			return "route:" + pattern
+		}
+		return ""
+	}
+}
+
+// Keys returns a KeyFunc which combines the keys, for example the client IP and the route.
+// The request is not rate limited if one of the keys is empty.
+func Keys(keys ...KeyFunc) KeyFunc {
+	return func(r *http.Request) string {
+		parts := make([]string, len(keys))
+		for i, key := range keys {
+			if parts[i] = key(r); parts[i] == "" {
+				return ""
+			}
+		}
+		return strings.Join(parts, "|")
+	}
+}
+
+// RateLimitOptions represents the configuration of the RateLimit middleware.
+// Zero values are replaced with defaults.
+type RateLimitOptions struct {
+	Limit     Limit     // Number of requests allowed per key, it is required.
+	Key       KeyFunc   // Key the requests are limited by (KeyByIP(nil) by default).
+	Store     Store     // Store of the counters (NewMemoryStore(0) by default).
+	Formatter Formatter // Formatter of the 429 Too Many Requests response (JSON by default).
+}
+
+// RateLimit limits the number of requests per key.
+// The state of the limit is sent in the RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy
+// headers. Requests over the limit are answered with 429 Too Many Requests and the Retry-After header.
+// If the Store fails, the request is allowed.
+func RateLimit(opts *RateLimitOptions) func(http.Handler) http.Handler {
+	o := RateLimitOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Limit.Requests <= 0 || o.Limit.Window <= 0 {
+		panic("httpserver: RateLimit requires positive Limit.Requests and Limit.Window")
+	}
+	if o.Key == nil {
+		o.Key = KeyByIP(nil)
+	}
+	if o.Store == nil {
+		o.Store = NewMemoryStore(0)
+	}
+	if o.Formatter == nil {
+		o.Formatter = NewFormatter(coder.NewCoder("application/json", json.Marshal, json.Unmarshal, false))
+	}
+
+	policy := strconv.Itoa(o.Limit.burst()) + ";w=" + strconv.Itoa(seconds(o.Limit.Window))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			key := o.Key(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			d, err := o.Store.Allow(ctx, key, o.Limit)
+			if err != nil {
+				slog.ErrorContext(ctx, "rate limit", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(seconds(d.Reset)))
+			h.Set("RateLimit-Policy", policy)
+
+			if !d.Allowed {
+				h.Set("Retry-After", strconv.Itoa(max(seconds(d.RetryAfter), 1)))
+				o.Formatter.WriteError(ctx, w, problem.New(http.StatusTooManyRequests, "rate limit exceeded"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// seconds returns the duration in whole seconds rounded up.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,163 @@
+package httpserver
+
+import (
+	"context"
+	"hash/maphash"
+	"math"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+// MemoryStore is an in-memory Store. The keys are spread over shards, each with its own lock,
+// and expired keys are removed while the shard is used.
+type MemoryStore struct {
+	seed   maphash.Seed
+	shards []*memoryShard
+}
+
+type memoryShard struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	swept   time.Time
+}
+
+// memoryEntry is the state of a key: the token bucket or the sliding window counters.
+type memoryEntry struct {
+	tokens float64   // token bucket: tokens left
+	last   time.Time // token bucket: time of the last refill; sliding window: start of the current window
+
+	previous, current int // sliding window: requests in the previous and in the current window
+
+	expires time.Time
+}
+
+// NewMemoryStore returns a new MemoryStore with the number of shards, 0 means 4 shards per CPU.
+func NewMemoryStore(shards int) *MemoryStore {
+	if shards <= 0 {
+		shards = 4 * runtime.GOMAXPROCS(0)
+	}
+
+	s := &MemoryStore{seed: maphash.MakeSeed(), shards: make([]*memoryShard, shards)}
+	for i := range s.shards {
+		s.shards[i] = &memoryShard{entries: make(map[string]*memoryEntry)}
+	}
+
+	return s
+}
+
+// Allow counts the request with the key and decides whether it is within the limit.
+func (s *MemoryStore) Allow(_ context.Context, key string, limit Limit) (Decision, error) {
+	if limit.Requests <= 0 || limit.Window <= 0 {
+		return Decision{}, ErrInvalidLimit
+	}
+
+	shard := s.shards[maphash.String(s.seed, key)%uint64(len(s.shards))]
+	now := time.Now()
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if now.Sub(shard.swept) > sweepInterval {
+		for k, e := range shard.entries {
+			if now.After(e.expires) {
+				delete(shard.entries, k)
+			}
+		}
+		shard.swept = now
+	}
+
+	e, ok := shard.entries[key]
+	if !ok || now.After(e.expires) {
+		e = &memoryEntry{tokens: float64(limit.burst()), last: now}
+		shard.entries[key] = e
+	}
+
+	if limit.Algorithm == SlidingWindow {
+		return e.slidingWindow(now, limit), nil
+	}
+
+	return e.tokenBucket(now, limit), nil
+}
+
+func (e *memoryEntry) tokenBucket(now time.Time, limit Limit) Decision {
+	burst := float64(limit.burst())
+	rate := float64(limit.Requests) / limit.Window.Seconds() // tokens per second
+
+	e.tokens = math.Min(burst, e.tokens+now.Sub(e.last).Seconds()*rate)
+	e.last = now
+
+	d := Decision{Limit: limit.burst()}
+
+	if e.tokens >= 1 {
+		e.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = durationOf((1 - e.tokens) / rate)
+	}
+
+	d.Remaining = int(e.tokens)
+	d.Reset = durationOf((burst - e.tokens) / rate)
+	e.expires = now.Add(d.Reset)
+
+	return d
+}
+
+// slidingWindow approximates the number of requests in the last window by weighting the previous window
+// with the part of it that is still in the last window.
+func (e *memoryEntry) slidingWindow(now time.Time, limit Limit) Decision {
+	window := limit.Window
+
+	if elapsed := now.Sub(e.last); elapsed >= window {
+		windows := elapsed / window
+		if windows == 1 {
+			e.previous = e.current
+		} else {
+			e.previous = 0
+		}
+		e.current = 0
+		e.last = e.last.Add(windows * window)
+	}
+
+	elapsed := now.Sub(e.last)
+	weight := 1 - float64(elapsed)/float64(window)
+	count := float64(e.previous)*weight + float64(e.current)
+
+	d := Decision{Limit: limit.Requests}
+
+	if count+1 <= float64(limit.Requests) {
+		e.current++
+		count++
+		d.Allowed = true
+	} else {
+		d.RetryAfter = e.retryAfter(elapsed, window, float64(limit.Requests))
+	}
+
+	d.Remaining = max(int(float64(limit.Requests)-count), 0)
+	d.Reset = window - elapsed
+	if e.current > 0 {
+		d.Reset += window
+	}
+	e.expires = e.last.Add(2 * window)
+
+	return d
+}
+
+// retryAfter returns the time until the weighted count drops enough for one more request.
+func (e *memoryEntry) retryAfter(elapsed, window time.Duration, limit float64) time.Duration {
+	free := limit - 1
+	if float64(e.current) <= free && e.previous > 0 {
+		// within the current window: previous*(1-t/window) + current <= free
+		t := float64(window) * (1 - (free-float64(e.current))/float64(e.previous))
+		return time.Duration(t) - elapsed
+	}
+	// in the next window the current one becomes the previous: current*(1-t/window) <= free
+	t := float64(window) * (1 - free/float64(e.current))
+	return window - elapsed + time.Duration(max(t, 0))
+}
+
+func durationOf(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,149 @@
+package httpserver_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/easy-techno-lab/proton/coder"
+	"github.com/easy-techno-lab/proton/httpserver"
+	"github.com/easy-techno-lab/proton/problem"
+)
+
+func TestMemoryStore_Allow(t *testing.T) {
+	var tests = []struct {
+		name    string
+		limit   httpserver.Limit
+		allowed []bool
+	}{
+		{
+			name:    "token bucket",
+			limit:   httpserver.Limit{Requests: 2, Window: time.Hour},
+			allowed: []bool{true, true, false, false},
+		},
+		{
+			name:    "token bucket with burst",
+			limit:   httpserver.Limit{Requests: 1, Window: time.Hour, Burst: 3},
+			allowed: []bool{true, true, true, false},
+		},
+		{
+			name:    "sliding window",
+			limit:   httpserver.Limit{Algorithm: httpserver.SlidingWindow, Requests: 3, Window: time.Hour},
+			allowed: []bool{true, true, true, false},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := httpserver.NewMemoryStore(2)
+
+			for i, exp := range test.allowed {
+				d, err := store.Allow(context.Background(), "key", test.limit)
+				equal(t, nil, err)
+				equal(t, exp, d.Allowed)
+
+				if exp {
+					equal(t, i+1, d.Limit-d.Remaining)
+					equal(t, time.Duration(0), d.RetryAfter)
+				} else {
+					equal(t, 0, d.Remaining)
+					equal(t, true, d.RetryAfter > 0 && d.RetryAfter <= 2*test.limit.Window)
+				}
+			}
+
+			// other keys are not affected
+			d, err := store.Allow(context.Background(), "other", test.limit)
+			equal(t, nil, err)
+			equal(t, true, d.Allowed)
+		})
+	}
+
+	_, err := httpserver.NewMemoryStore(0).Allow(context.Background(), "key", httpserver.Limit{})
+	equal(t, httpserver.ErrInvalidLimit, err)
+}
+
+func TestMemoryStore_Refill(t *testing.T) {
+	for _, algorithm := range []httpserver.RateLimitAlgorithm{httpserver.TokenBucket, httpserver.SlidingWindow} {
+		store := httpserver.NewMemoryStore(1)
+		limit := httpserver.Limit{Algorithm: algorithm, Requests: 1, Window: 50 * time.Millisecond}
+
+		d, _ := store.Allow(context.Background(), "key", limit)
+		equal(t, true, d.Allowed)
+
+		d, _ = store.Allow(context.Background(), "key", limit)
+		equal(t, false, d.Allowed)
+
+		time.Sleep(d.RetryAfter + 5*time.Millisecond)
+
+		d, _ = store.Allow(context.Background(), "key", limit)
+		equal(t, true, d.Allowed)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	cdrJSON := coder.NewCoder("application/json", json.Marshal, json.Unmarshal, false)
+
+	handler := httpserver.MiddlewareSequencer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		httpserver.RateLimit(&httpserver.RateLimitOptions{
+			Limit:     httpserver.Limit{Requests: 1, Window: time.Minute},
+			Key:       httpserver.KeyByAPIKey("X-API-Key", "api_key"),
+			Formatter: httpserver.NewFormatter(cdrJSON),
+		}),
+	)
+
+	serve := func(apiKey string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if apiKey != "" {
+			r.Header.Set("X-API-Key", apiKey)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve("first")
+	equal(t, http.StatusOK, w.Code)
+	equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	equal(t, "60", w.Header().Get("RateLimit-Reset"))
+	equal(t, "1;w=60", w.Header().Get("RateLimit-Policy"))
+
+	w = serve("first")
+	equal(t, http.StatusTooManyRequests, w.Code)
+	equal(t, "60", w.Header().Get("Retry-After"))
+	equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+
+	var details problem.Details
+	equal(t, nil, json.Unmarshal(w.Body.Bytes(), &details))
+	equal(t, http.StatusTooManyRequests, details.Status)
+
+	equal(t, http.StatusOK, serve("second").Code)
+
+	// requests without the key are not limited
+	equal(t, http.StatusOK, serve("").Code)
+	equal(t, http.StatusOK, serve("").Code)
+	equal(t, "", serve("").Header().Get("RateLimit-Limit"))
+}
+
+func TestKeys(t *testing.T) {
+	key := httpserver.Keys(httpserver.KeyByIP(nil), httpserver.KeyBySubject(), httpserver.KeyByHeader("X-Tenant"))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("X-Tenant", "acme")
+	equal(t, "", key(r))
+
+	r = r.WithContext(httpserver.ContextWithSubject(r.Context(), "user-1"))
+	equal(t, "ip:192.0.2.1|subject:user-1|header:acme", key(r))
+}
+
+func TestRateLimit_NilOptions(t *testing.T) {
+	defer func() {
+		equal(t, "httpserver: RateLimit requires positive Limit.Requests and Limit.Window", recover())
+	}()
+	httpserver.RateLimit(nil)
+}
@@ -0,0 +1,172 @@
+package httpserver
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ResponseWriter wraps http.ResponseWriter and records the status code, the number of bytes written,
+// the time of the first byte and the first write error, so that middleware can read them after the handler returns.
+//
+// ResponseWriter implements http.Flusher, http.Hijacker, http.Pusher and io.ReaderFrom by delegating to the wrapped
+// http.ResponseWriter, methods that it does not support return http.ErrNotSupported. Unwrap allows
+// http.ResponseController to reach the wrapped http.ResponseWriter for deadlines and full duplex.
+type ResponseWriter struct {
+	w http.ResponseWriter
+
+	status    int
+	bytes     int64
+	firstByte time.Time
+	err       error
+	hijacked  bool
+
+	taps []io.Writer
+}
+
+// NewResponseWriter returns a ResponseWriter which wraps w.
+// If w is already a *ResponseWriter, it is returned as is, so that nested middleware share the records.
+func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	if rw, ok := w.(*ResponseWriter); ok {
+		return rw
+	}
+	return &ResponseWriter{w: w}
+}
+
+// Header returns the header map of the wrapped http.ResponseWriter.
+func (w *ResponseWriter) Header() http.Header {
+	return w.w.Header()
+}
+
+// WriteHeader sends the response header with the status code, see http.ResponseWriter.
+// Informational 1xx status codes are sent without being recorded.
+func (w *ResponseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 && (statusCode < 100 || statusCode > 199 || statusCode == http.StatusSwitchingProtocols) {
+		w.status = statusCode
+		w.firstByte = time.Now()
+	}
+	w.w.WriteHeader(statusCode)
+}
+
+// Write writes the data as a part of the response body, see http.ResponseWriter.
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	w.writeHeader()
+
+	n, err := w.w.Write(b)
+	w.record(b[:n], err)
+
+	return n, err
+}
+
+// ReadFrom copies the data from r to the response body using io.ReaderFrom of the wrapped http.ResponseWriter
+// if it is available, it allows net/http to use sendfile.
+func (w *ResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	rf, ok := w.w.(io.ReaderFrom)
+	if !ok || len(w.taps) != 0 {
+		return io.Copy(writerOnly{w}, r)
+	}
+
+	w.writeHeader()
+
+	n, err := rf.ReadFrom(r)
+	w.bytes += n
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+
+	return n, err
+}
+
+// Flush sends any buffered data to the client, see http.Flusher.
+func (w *ResponseWriter) Flush() {
+	_ = w.FlushError()
+}
+
+// FlushError sends any buffered data to the client and returns http.ErrNotSupported if flushing is not supported.
+func (w *ResponseWriter) FlushError() error {
+	w.writeHeader()
+	return http.NewResponseController(w.w).Flush()
+}
+
+// Hijack lets the caller take over the connection, see http.Hijacker.
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.w).Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Push initiates an HTTP/2 server push, see http.Pusher.
+func (w *ResponseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.w.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the wrapped http.ResponseWriter for http.ResponseController.
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.w
+}
+
+// Status returns the status code of the response.
+// If the handler has not written anything, it returns 200 OK which net/http sends when the handler returns,
+// or 0 if the connection has been hijacked.
+func (w *ResponseWriter) Status() int {
+	if w.status == 0 && !w.hijacked {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Written reports whether the response header has been written.
+func (w *ResponseWriter) Written() bool {
+	return w.status != 0
+}
+
+// BytesWritten returns the number of bytes of the response body written.
+func (w *ResponseWriter) BytesWritten() int64 {
+	return w.bytes
+}
+
+// FirstByte returns the time the response header was written, it is zero if it has not been written yet.
+func (w *ResponseWriter) FirstByte() time.Time {
+	return w.firstByte
+}
+
+// Err returns the first error returned by writing the response body.
+func (w *ResponseWriter) Err() error {
+	return w.err
+}
+
+// Tee additionally writes the response body to dst, errors of dst are ignored.
+// It is intended for middleware that captures the response, dst must not block.
+func (w *ResponseWriter) Tee(dst io.Writer) {
+	w.taps = append(w.taps, dst)
+}
+
+// writeHeader records the implicit 200 OK status sent by net/http on the first write.
+func (w *ResponseWriter) writeHeader() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+		w.firstByte = time.Now()
+	}
+}
+
+func (w *ResponseWriter) record(b []byte, err error) {
+	w.bytes += int64(len(b))
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	for _, tap := range w.taps {
+		_, _ = tap.Write(b)
+	}
+}
+
+// writerOnly hides the io.ReaderFrom of the ResponseWriter from io.Copy.
+type writerOnly struct {
+	io.Writer
+}
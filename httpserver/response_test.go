@@ -0,0 +1,141 @@
+package httpserver_test
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/easy-techno-lab/proton/httpserver"
+)
+
+func TestResponseWriter(t *testing.T) {
+	var tests = []struct {
+		name       string
+		handler    http.HandlerFunc
+		statusCode int
+		bytes      int64
+		written    bool
+	}{
+		{
+			name:       "nothing written",
+			handler:    func(w http.ResponseWriter, r *http.Request) {},
+			statusCode: http.StatusOK,
+		},
+		{
+			name: "implicit status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, "hello")
+			},
+			statusCode: http.StatusOK,
+			bytes:      5,
+			written:    true,
+		},
+		{
+			name: "first status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				w.WriteHeader(http.StatusAccepted)
+				_, _ = io.Copy(w, strings.NewReader("created"))
+			},
+			statusCode: http.StatusCreated,
+			bytes:      7,
+			written:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rw := httpserver.NewResponseWriter(httptest.NewRecorder())
+			equal(t, rw, httpserver.NewResponseWriter(rw))
+
+			test.handler(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			equal(t, test.statusCode, rw.Status())
+			equal(t, test.bytes, rw.BytesWritten())
+			equal(t, test.written, rw.Written())
+			equal(t, test.written, !rw.FirstByte().IsZero())
+			equal(t, nil, rw.Err())
+		})
+	}
+}
+
+func TestResponseWriter_Interfaces(t *testing.T) {
+	flushed := make(chan struct{})
+
+	handler := httpserver.MiddlewareSequencer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hijack" {
+			conn, buf, err := http.NewResponseController(w).Hijack()
+			equal(t, nil, err)
+			_, _ = buf.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+			_ = buf.Flush()
+			_ = conn.Close()
+			return
+		}
+
+		_, ok := w.(http.Pusher)
+		equal(t, true, ok)
+
+		equal(t, nil, http.NewResponseController(w).SetWriteDeadline(time.Now().Add(time.Minute)))
+
+		_, _ = io.WriteString(w, "first")
+		w.(http.Flusher).Flush()
+		<-flushed
+		_, _ = io.WriteString(w, "second")
+	}),
+		httpserver.DumpHttp(slog.LevelError),
+		httpserver.Timer(slog.LevelError),
+		httpserver.Tracer,
+	)
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	// the first chunk must reach the client before the handler finishes
+	resp, err := http.Get(srv.URL)
+	equal(t, nil, err)
+
+	b := make([]byte, 5)
+	_, err = io.ReadFull(resp.Body, b)
+	equal(t, nil, err)
+	equal(t, "first", string(b))
+	close(flushed)
+
+	b, err = io.ReadAll(resp.Body)
+	equal(t, nil, err)
+	equal(t, "second", string(b))
+	_ = resp.Body.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	equal(t, nil, err)
+	defer func() { _ = conn.Close() }()
+
+	_, err = io.WriteString(conn, "GET /hijack HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	equal(t, nil, err)
+
+	resp, err = http.ReadResponse(bufio.NewReader(conn), nil)
+	equal(t, nil, err)
+	b, err = io.ReadAll(resp.Body)
+	equal(t, nil, err)
+	equal(t, "hijacked", string(b))
+}
+
+func TestResponseWriter_NotSupported(t *testing.T) {
+	rw := httpserver.NewResponseWriter(struct{ http.ResponseWriter }{httptest.NewRecorder()})
+
+	_, _, err := rw.Hijack()
+	equal(t, true, errors.Is(err, http.ErrNotSupported))
+	equal(t, true, errors.Is(rw.Push("/style.css", nil), http.ErrNotSupported))
+	equal(t, true, errors.Is(rw.FlushError(), http.ErrNotSupported))
+
+	n, err := rw.ReadFrom(strings.NewReader("body"))
+	equal(t, nil, err)
+	equal(t, int64(4), n)
+	equal(t, int64(4), rw.BytesWritten())
+}
@@ -0,0 +1,274 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrRouteNotFound = errors.New("route not found")
+	ErrParamMissing  = errors.New("parameter is missing")
+)
+
+// Router is a wrapper around *http.ServeMux which supports route groups with their own middleware,
+// named routes and automatic handling of OPTIONS requests.
+// Patterns have the *http.ServeMux syntax: [METHOD ][HOST]/[PATH], path wildcards are available
+// through PathParam and *http.Request.PathValue.
+// For requests with a registered path but a method that is not registered, the router responds
+// with 405 Method Not Allowed and the Allow header.
+type Router struct {
+	prefix string
+	mws    []func(http.Handler) http.Handler
+	routes *routes
+}
+
+// NewRouter returns a new Router.
+func NewRouter() *Router {
+	return &Router{
+		routes: &routes{
+			mux:     http.NewServeMux(),
+			names:   make(map[string]*Route),
+			methods: make(map[string][]string),
+			options: make(map[string]http.Handler),
+		},
+	}
+}
+
+// Use adds middleware to the Router. It affects only routes registered after the call.
+// Like in MiddlewareSequencer, the last middleware is the outermost one.
+func (rt *Router) Use(mws ...func(http.Handler) http.Handler) {
+	rt.mws = append(mws[:len(mws):len(mws)], rt.mws...)
+}
+
+// Group returns a new Router which registers routes on the same *http.ServeMux with the path prefix.
+// The middleware of the group wraps the route handlers inside the middleware of the parent Router.
+func (rt *Router) Group(prefix string, mws ...func(http.Handler) http.Handler) *Router {
+	return &Router{
+		prefix: rt.prefix + strings.TrimSuffix(prefix, "/"),
+		mws:    append(mws[:len(mws):len(mws)], rt.mws...),
+		routes: rt.routes,
+	}
+}
+
+// Handle registers the handler for the given pattern.
+func (rt *Router) Handle(pattern string, handler http.Handler) *Route {
+	method, host, path := splitPattern(pattern)
+
+	path = rt.prefix + path
+	if path == "" {
+		path = "/"
+	}
+
+	route := &Route{router: rt, Method: method, Pattern: strings.TrimSpace(method + " " + host + path), path: path}
+
+	rt.routes.add(route, host+path, handler, rt.mws)
+
+	return route
+}
+
+// HandleFunc registers the handler function for the given pattern.
+func (rt *Router) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) *Route {
+	return rt.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// ServeHTTP dispatches the request to the handler whose pattern most closely matches the request.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.routes.mux.ServeHTTP(w, r)
+}
+
+// URL builds the path of the named route, params are pairs of wildcard names and values.
+func (rt *Router) URL(name string, params ...string) (string, error) {
+	rt.routes.mu.RLock()
+	route, ok := rt.routes.names[name]
+	rt.routes.mu.RUnlock()
+
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrRouteNotFound, name)
+	}
+
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i+1 < len(params); i += 2 {
+		values[params[i]] = params[i+1]
+	}
+
+	segments := strings.Split(route.path, "/")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			continue
+		}
+
+		wildcard := strings.TrimSuffix(segment[1:len(segment)-1], "...")
+		if wildcard == "$" {
+			segments[i] = ""
+			continue
+		}
+
+		value, ok := values[wildcard]
+		if !ok {
+			return "", fmt.Errorf("route %q parameter %q: %w", name, wildcard, ErrParamMissing)
+		}
+
+		if strings.HasSuffix(segment, "...}") {
+			parts := strings.Split(value, "/")
+			for j := range parts {
+				parts[j] = url.PathEscape(parts[j])
+			}
+			segments[i] = strings.Join(parts, "/")
+		} else {
+			segments[i] = url.PathEscape(value)
+		}
+	}
+
+	return strings.Join(segments, "/"), nil
+}
+
+// Route is a registered route.
+type Route struct {
+	Method  string // Method of the route, empty if the route matches all methods.
+	Pattern string // Full pattern of the route including the group prefix.
+
+	router *Router
+	path   string
+}
+
+// Name names the route, so that its URL can be built with Router.URL.
+func (r *Route) Name(name string) *Route {
+	r.router.routes.mu.Lock()
+	defer r.router.routes.mu.Unlock()
+
+	r.router.routes.names[name] = r
+
+	return r
+}
+
+// routes is the state shared by the Router and its groups.
+type routes struct {
+	mux *http.ServeMux
+
+	mu      sync.RWMutex
+	names   map[string]*Route
+	methods map[string][]string     // registered methods by host and path
+	options map[string]http.Handler // OPTIONS handlers registered by the user
+}
+
+func (rs *routes) add(route *Route, key string, handler http.Handler, mws []func(http.Handler) http.Handler) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	handler = withRoutePattern(route.Pattern, MiddlewareSequencer(handler, mws...))
+
+	if route.Method == "" {
+		rs.mux.Handle(route.Pattern, handler)
+		return
+	}
+
+	methods, registered := rs.methods[key]
+	if !registered {
+		// the default response passes through the middleware of the route which registered the path first,
+		// so that AllowCORS can handle preflight requests
+		noContent := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+		pattern := http.MethodOptions + " " + key
+		rs.mux.Handle(pattern, rs.optionsHandler(key, withRoutePattern(pattern, MiddlewareSequencer(noContent, mws...))))
+	}
+
+	if route.Method == http.MethodOptions {
+		rs.options[key] = handler
+	} else {
+		rs.mux.Handle(route.Pattern, handler)
+	}
+
+	if !slices.Contains(methods, route.Method) {
+		rs.methods[key] = append(methods, route.Method)
+	}
+}
+
+// optionsHandler responds to OPTIONS requests with the Allow header
+// and calls the OPTIONS handler registered by the user, if any.
+func (rs *routes) optionsHandler(key string, def http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", rs.allow(key))
+
+		rs.mu.RLock()
+		handler, ok := rs.options[key]
+		rs.mu.RUnlock()
+
+		if !ok {
+			handler = def
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// allow returns the value of the Allow header for the path.
+func (rs *routes) allow(key string) string {
+	rs.mu.RLock()
+	methods := slices.Clone(rs.methods[key])
+	rs.mu.RUnlock()
+
+	if slices.Contains(methods, http.MethodGet) && !slices.Contains(methods, http.MethodHead) {
+		methods = append(methods, http.MethodHead)
+	}
+	if !slices.Contains(methods, http.MethodOptions) {
+		methods = append(methods, http.MethodOptions)
+	}
+	slices.Sort(methods)
+
+	return strings.Join(methods, ", ")
+}
+
+// splitPattern splits the *http.ServeMux pattern into method, host and path.
+func splitPattern(pattern string) (method, host, path string) {
+	pattern = strings.TrimSpace(pattern)
+	if m, rest, found := strings.Cut(pattern, " "); found {
+		method, pattern = m, strings.TrimLeft(rest, " \t")
+	}
+
+	if i := strings.IndexByte(pattern, '/'); i >= 0 {
+		return method, pattern[:i], pattern[i:]
+	}
+
+	return method, pattern, ""
+}
+
+type routeInfo struct {
+	pattern string
+}
+
+// withRoutePattern stores the route pattern in the request context.
+func withRoutePattern(pattern string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if info, ok := r.Context().Value(routeCtxKey).(*routeInfo); ok {
+			info.pattern = pattern
+		} else {
+			r = r.WithContext(context.WithValue(r.Context(), routeCtxKey, &routeInfo{pattern: pattern}))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withRouteInfo adds a placeholder for the route pattern to the request context,
+// so that middleware wrapped around the Router can read the pattern after the request is served.
+func withRouteInfo(r *http.Request) (*http.Request, *routeInfo) {
+	if info, ok := r.Context().Value(routeCtxKey).(*routeInfo); ok {
+		return r, info
+	}
+	info := new(routeInfo)
+	return r.WithContext(context.WithValue(r.Context(), routeCtxKey, info)), info
+}
+
+// RoutePattern returns the pattern of the Router route that matched the request.
+func RoutePattern(r *http.Request) string {
+	if info, ok := r.Context().Value(routeCtxKey).(*routeInfo); ok {
+		return info.pattern
+	}
+	return ""
+}
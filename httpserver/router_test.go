@@ -0,0 +1,145 @@
+package httpserver_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/easy-techno-lab/proton/httpserver"
+)
+
+func header(key, value string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add(key, value)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestRouter(t *testing.T) {
+	router := httpserver.NewRouter()
+	router.Use(header("X-Middleware", "root"))
+
+	api := router.Group("/api", header("X-Middleware", "api"), httpserver.AllowCORS(&httpserver.CORSOptions{
+		AllowOrigins: []string{"*"},
+		AllowMethods: []string{"GET", "POST"},
+	}))
+
+	api.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := httpserver.PathParam[int](r, "id")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_, _ = fmt.Fprintf(w, "%s %d", httpserver.RoutePattern(r), id)
+	}).Name("user")
+
+	api.HandleFunc("POST /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	router.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {})
+
+	var tests = []struct {
+		name       string
+		method     string
+		path       string
+		origin     string
+		statusCode int
+		body       string
+		header     http.Header
+	}{
+		{
+			name:       "GET route with path parameter",
+			method:     http.MethodGet,
+			path:       "/api/users/42",
+			statusCode: http.StatusOK,
+			body:       "GET /api/users/{id} 42",
+			header:     http.Header{"X-Middleware": {"root", "api"}},
+		},
+		{
+			name:       "invalid path parameter",
+			method:     http.MethodGet,
+			path:       "/api/users/abc",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "POST route",
+			method:     http.MethodPost,
+			path:       "/api/users/42",
+			statusCode: http.StatusCreated,
+		},
+		{
+			name:       "method not allowed",
+			method:     http.MethodDelete,
+			path:       "/api/users/42",
+			statusCode: http.StatusMethodNotAllowed,
+			header:     http.Header{"Allow": {"GET, HEAD, OPTIONS, POST"}},
+		},
+		{
+			name:       "OPTIONS",
+			method:     http.MethodOptions,
+			path:       "/health",
+			statusCode: http.StatusNoContent,
+			header:     http.Header{"Allow": {"GET, HEAD, OPTIONS"}, "X-Middleware": {"root"}},
+		},
+		{
+			name:       "CORS preflight",
+			method:     http.MethodOptions,
+			path:       "/api/users/42",
+			origin:     "https://example.com",
+			statusCode: http.StatusOK,
+			header: http.Header{
+				"Access-Control-Allow-Origin":  {"https://example.com"},
+				"Access-Control-Allow-Methods": {"GET,POST"},
+			},
+		},
+		{
+			name:       "not found",
+			method:     http.MethodGet,
+			path:       "/users/42",
+			statusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, test.path, nil)
+			if test.origin != "" {
+				r.Header.Set("Origin", test.origin)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			equal(t, test.statusCode, w.Code)
+			if test.body != "" {
+				equal(t, test.body, strings.TrimSpace(w.Body.String()))
+			}
+			for key, values := range test.header {
+				equal(t, values, w.Header().Values(key))
+			}
+		})
+	}
+}
+
+func TestRouter_URL(t *testing.T) {
+	router := httpserver.NewRouter()
+	files := router.Group("/files/")
+
+	files.HandleFunc("GET /{bucket}/{path...}", func(http.ResponseWriter, *http.Request) {}).Name("file")
+
+	u, err := router.URL("file", "bucket", "my bucket", "path", "a/b c.txt")
+	equal(t, nil, err)
+	equal(t, "/files/my%20bucket/a/b%20c.txt", u)
+
+	_, err = router.URL("file", "bucket", "b")
+	equal(t, true, errors.Is(err, httpserver.ErrParamMissing))
+
+	_, err = router.URL("unknown")
+	equal(t, true, errors.Is(err, httpserver.ErrRouteNotFound))
+}
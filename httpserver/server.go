@@ -2,22 +2,18 @@ package httpserver
 
 import (
 	"context"
-	"errors"
-	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
-	"os"
-	"os/signal"
-	"sync/atomic"
-	"syscall"
-	"time"
 
 	"github.com/easy-techno-lab/proton/coder"
+	"github.com/easy-techno-lab/proton/problem"
 )
 
 type Formatter interface {
 	coder.Coder
 	WriteResponse(ctx context.Context, w http.ResponseWriter, statusCode int, v any)
+	WriteError(ctx context.Context, w http.ResponseWriter, err error)
 }
 
 // NewFormatter returns a new Formatter.
@@ -41,124 +37,75 @@ func (f *protoFormatter) WriteResponse(ctx context.Context, w http.ResponseWrite
 	}
 	w.WriteHeader(statusCode)
 	if err := f.Encode(ctx, w, v); err != nil {
+		// the status code has already been sent, so the error can only be logged
 		slog.ErrorContext(ctx, "encode response", "error", err)
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 	}
 }
 
-// Controller is a wrapper around *http.Server to control the server.
-//
-//	Server — *http.Server, which will be managed.
-//	GracefulTimeout — time that is given to the server to shut down gracefully.
-type Controller struct {
-	Server          *http.Server
-	GracefulTimeout time.Duration
+// WriteError writes the error as RFC 9457 problem details encoded with the Coder.
+// The Content-Type is application/problem+json for JSON Coders, application/problem+xml for XML Coders
+// and the content type of the Coder otherwise. See problem.From for how errors are converted.
+// Server errors are logged.
+func (f *protoFormatter) WriteError(ctx context.Context, w http.ResponseWriter, err error) {
+	p := problem.From(err)
 
-	isRan   atomic.Bool
-	restart atomic.Bool
-
-	sigint chan os.Signal
-}
-
-// Start starts the *http.Server.
-// If *tls.Config on the server is non nil, the server listens and serves using tls.
-func (c *Controller) Start() (err error) {
-	for {
-		if err = c.start(); errors.Is(err, http.ErrServerClosed) {
-			err = nil
-		}
-		if !c.restart.Load() {
-			slog.Info("HTTP server is shutdown")
-			return
-		} else if err != nil {
-			slog.Error(err.Error())
-		}
-
-		slog.Info("HTTP server is restarting")
-
-		c.clone()
-		c.restart.Store(false)
+	if p.Status >= http.StatusInternalServerError {
+		slog.ErrorContext(ctx, "server error", "error", err)
 	}
-}
 
-// Restart restarts the server if necessary.
-// For changes to the following parameters to take effect:
-//
-//	Addr; TLSConfig; TLSNextProto; ConnState; BaseContext; ConnContext,
-//
-// a server restart is required.
-// Other parameters can be changed without restarting the server.
-// If the server is not running, the function will be skipped.
-func (c *Controller) Restart() {
-	if !c.isRan.Load() {
-		return
+	if contentType := problem.ContentTypeFor(f.ContentType()); contentType != "" {
+		w.Header().Set(coder.ContentType, contentType)
 	}
 
-	c.restart.Store(true)
-
-	c.sigint <- syscall.SIGINT
+	f.WriteResponse(ctx, w, p.Status, p)
 }
 
-// Shutdown gracefully shuts down the server.
-func (c *Controller) Shutdown() {
-	ctx, cancelWithTimeout := context.WithTimeout(context.Background(), c.GracefulTimeout)
-	defer cancelWithTimeout()
-
-	if err := c.Server.Shutdown(ctx); err != nil {
-		slog.Error(fmt.Sprintf("HTTP server shutdown: %s", err))
-	}
+// NewRegistryFormatter returns a new Formatter which uses the Coders chosen by the Negotiate middleware.
+// If the request has not passed through the Negotiate middleware, the default Coder of the Registry is used.
+func NewRegistryFormatter(registry *coder.Registry) Formatter {
+	return &registryFormatter{registry: registry}
 }
 
-func (c *Controller) start() error {
-	c.sigint = make(chan os.Signal, 1)
-	signal.Notify(c.sigint, syscall.SIGINT, syscall.SIGTERM)
-
-	defer func() {
-		signal.Stop(c.sigint)
-		close(c.sigint)
-	}()
+type registryFormatter struct {
+	registry *coder.Registry
+}
 
-	secure := c.Server.TLSConfig != nil
+// ContentType returns the content type of the default Coder.
+func (f *registryFormatter) ContentType() string {
+	return f.registry.Default().ContentType()
+}
 
-	go func() {
-		<-c.sigint
-		c.Shutdown()
-	}()
+// Encode encodes the value pointed to by v with the negotiated response Coder and writes it to the stream.
+func (f *registryFormatter) Encode(ctx context.Context, w io.Writer, v any) error {
+	return f.responseCoder(ctx).Encode(ctx, w, v)
+}
 
-	c.isRan.Store(true)
-	defer c.isRan.Store(false)
+// Decode decodes the value from the stream with the negotiated request Coder and stores it in v.
+func (f *registryFormatter) Decode(ctx context.Context, r io.Reader, v any) error {
+	return f.requestCoder(ctx).Decode(ctx, r, v)
+}
 
-	slog.Info("HTTP server serving", "secure", secure, "address", c.Server.Addr)
+// WriteResponse encodes the value pointed to by v with the negotiated response Coder
+// and writes it and statusCode to the stream.
+func (f *registryFormatter) WriteResponse(ctx context.Context, w http.ResponseWriter, statusCode int, v any) {
+	(&protoFormatter{Coder: f.responseCoder(ctx)}).WriteResponse(ctx, w, statusCode, v)
+}
 
-	if secure {
-		err := c.Server.ListenAndServeTLS("", "")
-		return fmt.Errorf("HTTP server ListenAndServeTLS: %w", err)
-	} else {
-		err := c.Server.ListenAndServe()
-		return fmt.Errorf("HTTP server ListenAndServe: %w", err)
-	}
+// WriteError writes the error as RFC 9457 problem details encoded with the negotiated response Coder.
+func (f *registryFormatter) WriteError(ctx context.Context, w http.ResponseWriter, err error) {
+	(&protoFormatter{Coder: f.responseCoder(ctx)}).WriteError(ctx, w, err)
 }
 
-// clone clones the server before restarting, since it is impossible to start a stopped server.
-func (c *Controller) clone() {
-	if c.Server.TLSConfig != nil && len(c.Server.TLSConfig.Certificates) == 0 {
-		c.Server.TLSConfig = nil
+func (f *registryFormatter) requestCoder(ctx context.Context) coder.Coder {
+	if n, ok := ctx.Value(negotiatedCtxKey).(*negotiated); ok {
+		return n.request
 	}
+	return f.registry.Default()
+}
 
-	c.Server = &http.Server{
-		Addr:                         c.Server.Addr, // need to restart
-		Handler:                      c.Server.Handler,
-		DisableGeneralOptionsHandler: c.Server.DisableGeneralOptionsHandler,
-		TLSConfig:                    c.Server.TLSConfig, // need to restart
-		ReadTimeout:                  c.Server.ReadTimeout,
-		ReadHeaderTimeout:            c.Server.ReadHeaderTimeout,
-		WriteTimeout:                 c.Server.WriteTimeout,
-		IdleTimeout:                  c.Server.IdleTimeout,
-		MaxHeaderBytes:               c.Server.MaxHeaderBytes,
-		TLSNextProto:                 c.Server.TLSNextProto, // need to restart
-		ConnState:                    c.Server.ConnState,    // need to restart
-		ErrorLog:                     c.Server.ErrorLog,
-		BaseContext:                  c.Server.BaseContext, // need to restart
-		ConnContext:                  c.Server.ConnContext, // need to restart
+func (f *registryFormatter) responseCoder(ctx context.Context) coder.Coder {
+	if n, ok := ctx.Value(negotiatedCtxKey).(*negotiated); ok {
+		return n.response
 	}
+	return f.registry.Default()
 }
@@ -2,16 +2,24 @@ package httpserver_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/easy-techno-lab/proton/coder"
 	"github.com/easy-techno-lab/proton/httpserver"
+	"github.com/easy-techno-lab/proton/problem"
+	"github.com/easy-techno-lab/proton/trace"
+	"github.com/easy-techno-lab/proton/utils/log"
 )
 
 func equal(t *testing.T, exp, got any) {
@@ -170,3 +178,288 @@ func TestProtoFormatter_ResponseContentType(t *testing.T) {
 		})
 	}
 }
+
+func TestNegotiate(t *testing.T) {
+	cdrXML := coder.NewCoder("application/xml", xml.Marshal, xml.Unmarshal, false)
+	registry := coder.NewRegistry(cdrJSON, cdrXML)
+	formatter := httpserver.NewRegistryFormatter(registry)
+
+	handler := httpserver.Negotiate(registry)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		input := &serverTestStruct{}
+		if r.ContentLength != 0 {
+			err := formatter.Decode(ctx, r.Body, input)
+			equal(t, nil, err)
+		}
+
+		formatter.WriteResponse(ctx, w, http.StatusOK, input)
+	}))
+
+	var tests = []struct {
+		name        string
+		contentType string
+		accept      string
+		body        string
+		statusCode  int
+		respType    string
+		respBody    string
+	}{
+		{
+			name:       "default coder",
+			statusCode: http.StatusOK,
+			respType:   "application/json",
+			respBody:   `{"Field":0}`,
+		},
+		{
+			name:        "JSON request, XML response",
+			contentType: "application/json",
+			accept:      "application/xml, application/json;q=0.5",
+			body:        `{"Field":1}`,
+			statusCode:  http.StatusOK,
+			respType:    "application/xml",
+			respBody:    `<serverTestStruct><Field>1</Field></serverTestStruct>`,
+		},
+		{
+			name:        "unsupported media type",
+			contentType: "application/protobuf",
+			body:        "1",
+			statusCode:  http.StatusUnsupportedMediaType,
+		},
+		{
+			name:       "not acceptable",
+			accept:     "image/png",
+			statusCode: http.StatusNotAcceptable,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/path", strings.NewReader(test.body))
+			if test.contentType != "" {
+				r.Header.Set(coder.ContentType, test.contentType)
+			}
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			equal(t, test.statusCode, w.Code)
+			if test.respType != "" {
+				equal(t, test.respType, w.Header().Get(coder.ContentType))
+				equal(t, test.respBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestProtoFormatter_WriteError(t *testing.T) {
+	cdrXML := coder.NewCoder("application/xml", xml.Marshal, xml.Unmarshal, false)
+
+	var tests = []struct {
+		name        string
+		coder       coder.Coder
+		err         error
+		statusCode  int
+		contentType string
+		body        string
+	}{
+		{
+			name:        "problem details",
+			coder:       cdrJSON,
+			err:         problem.New(http.StatusConflict, "already exists").With("id", 1),
+			statusCode:  http.StatusConflict,
+			contentType: problem.ContentType,
+			body:        `{"detail":"already exists","id":1,"status":409,"title":"Conflict"}`,
+		},
+		{
+			name:        "wrapped problem details",
+			coder:       cdrJSON,
+			err:         fmt.Errorf("get user: %w", problem.New(http.StatusNotFound, "user not found")),
+			statusCode:  http.StatusNotFound,
+			contentType: problem.ContentType,
+			body:        `{"detail":"user not found","status":404,"title":"Not Found"}`,
+		},
+		{
+			name:        "unknown error is not exposed",
+			coder:       cdrJSON,
+			err:         errors.New("database is down"),
+			statusCode:  http.StatusInternalServerError,
+			contentType: problem.ContentType,
+			body:        `{"status":500,"title":"Internal Server Error"}`,
+		},
+		{
+			name:        "class sentinel",
+			coder:       cdrJSON,
+			err:         fmt.Errorf("validate: %w", problem.ErrClient),
+			statusCode:  http.StatusBadRequest,
+			contentType: problem.ContentType,
+			body:        `{"status":400,"title":"Bad Request"}`,
+		},
+		{
+			name:        "XML coder",
+			coder:       cdrXML,
+			err:         problem.New(http.StatusForbidden, ""),
+			statusCode:  http.StatusForbidden,
+			contentType: problem.ContentTypeXML,
+			body:        `<problem xmlns="urn:ietf:rfc:7807"><title>Forbidden</title><status>403</status></problem>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			httpserver.NewFormatter(test.coder).WriteError(context.Background(), w, test.err)
+
+			equal(t, test.statusCode, w.Code)
+			equal(t, test.contentType, w.Header().Get(coder.ContentType))
+			equal(t, test.body, w.Body.String())
+		})
+	}
+}
+
+func TestTracer(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	var tests = []struct {
+		name        string
+		traceparent string
+		tracestate  string
+		newTrace    bool
+	}{
+		{
+			name:        "continue trace",
+			traceparent: traceparent,
+			tracestate:  "vendor=value",
+		},
+		{
+			name:     "start trace",
+			newTrace: true,
+		},
+		{
+			name:        "invalid traceparent",
+			traceparent: "invalid",
+			newTrace:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sc trace.SpanContext
+
+			handler := httpserver.Tracer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var ok bool
+				sc, ok = trace.SpanContextFromContext(r.Context())
+				equal(t, true, ok)
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/path", nil)
+			if test.traceparent != "" {
+				r.Header.Set(trace.TraceparentHeader, test.traceparent)
+				r.Header.Set(trace.TracestateHeader, test.tracestate)
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			equal(t, true, sc.IsValid())
+
+			if !test.newTrace {
+				parent, err := trace.ParseTraceparent(test.traceparent)
+				equal(t, nil, err)
+				equal(t, parent.TraceID, sc.TraceID)
+				equal(t, false, parent.SpanID == sc.SpanID)
+				equal(t, test.tracestate, sc.State)
+			}
+		})
+	}
+}
+
+func TestTracer_Span(t *testing.T) {
+	exp := new(trace.InMemoryExporter)
+	trace.SetExporter(exp)
+	defer trace.SetExporter(nil)
+
+	router := httpserver.NewRouter()
+	router.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	handler := httpserver.MiddlewareSequencer(router, httpserver.Tracer)
+
+	r := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	r.Header.Set(trace.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := exp.Spans()
+	equal(t, 1, len(spans))
+
+	span := spans[0]
+	equal(t, "GET /users/{id}", span.Name)
+	equal(t, trace.KindServer, span.Kind)
+	equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID.String())
+	equal(t, "00f067aa0ba902b7", span.Parent.String())
+	equal(t, trace.StatusError, span.Status)
+	equal(t, []slog.Attr{
+		slog.String("http.request.method", http.MethodGet),
+		slog.String("url.path", "/users/42"),
+		slog.String("http.route", "GET /users/{id}"),
+		slog.Int("http.response.status_code", http.StatusBadGateway),
+	}, span.Attributes)
+}
+
+func TestTimer_Span(t *testing.T) {
+	exp := new(trace.InMemoryExporter)
+	trace.SetExporter(exp)
+	defer trace.SetExporter(nil)
+
+	var spans []trace.SpanContext
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		spans = append(spans, trace.SpanFromContext(r.Context()).SpanContext())
+	})
+
+	tests := []struct {
+		name string
+		mws  []func(http.Handler) http.Handler
+	}{
+		{name: "without tracer", mws: []func(http.Handler) http.Handler{httpserver.Timer(slog.LevelDebug)}},
+		{name: "with tracer", mws: []func(http.Handler) http.Handler{httpserver.Timer(slog.LevelDebug), httpserver.Tracer}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exp.Reset()
+			spans = nil
+
+			r := httptest.NewRequest(http.MethodGet, "/path", nil)
+			r.Header.Set(trace.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+			httpserver.MiddlewareSequencer(handler, test.mws...).ServeHTTP(httptest.NewRecorder(), r)
+
+			// the only server span is the one of the handler
+			exported := exp.Spans()
+			equal(t, 1, len(exported))
+			equal(t, trace.KindServer, exported[0].Kind)
+			equal(t, spans, []trace.SpanContext{exported[0].SpanContext})
+			equal(t, "00f067aa0ba902b7", exported[0].Parent.String())
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	handler := httpserver.RequestID("X-Request-ID")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		equal(t, w.Header().Get("X-Request-ID"), r.Context().Value(log.RequestIDCtxKey))
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/path", nil)
+	r.Header.Set("X-Request-ID", "request")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	equal(t, "request", w.Header().Get("X-Request-ID"))
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/path", nil))
+	equal(t, 12, len(w.Header().Get("X-Request-ID")))
+}
@@ -0,0 +1,63 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le
+
+package httpserver
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// soReusePort is SO_REUSEPORT, which is not defined in package syscall for every architecture.
+const soReusePort = 0xf
+
+func setReusePort(fd uintptr) error {
+	return os.NewSyscallError("setsockopt", syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1))
+}
+
+func setKeepAlive(fd uintptr, ka keepAlive) error {
+	opts := [][2]int{
+		{syscall.SOL_SOCKET, syscall.SO_KEEPALIVE},
+		{syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE},
+		{syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL},
+		{syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT},
+	}
+	values := []int{1, int(ka.idle / time.Second), int(ka.interval / time.Second), ka.count}
+
+	for i, opt := range opts {
+		if values[i] <= 0 {
+			continue // the default of the system
+		}
+		if err := syscall.SetsockoptInt(int(fd), opt[0], opt[1], values[i]); err != nil {
+			return os.NewSyscallError("setsockopt", err)
+		}
+	}
+
+	return nil
+}
+
+// getKeepAlive returns the keep-alive options of the socket, ok is false if keep-alive is not enabled on it.
+func getKeepAlive(fd uintptr) (ka keepAlive, ok bool) {
+	if on, err := syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE); err != nil || on == 0 {
+		return ka, false
+	}
+
+	idle, err := syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE)
+	if err != nil {
+		return ka, false
+	}
+	interval, err := syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL)
+	if err != nil {
+		return ka, false
+	}
+	count, err := syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT)
+	if err != nil {
+		return ka, false
+	}
+
+	return keepAlive{idle: time.Duration(idle) * time.Second, interval: time.Duration(interval) * time.Second, count: count}, true
+}
+
+func closeOnExec(fd int) {
+	syscall.CloseOnExec(fd)
+}
@@ -0,0 +1,22 @@
+//go:build !linux || mips || mipsle || mips64 || mips64le
+
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+)
+
+func setReusePort(uintptr) error {
+	return fmt.Errorf("SO_REUSEPORT: %w", errors.ErrUnsupported)
+}
+
+func setKeepAlive(uintptr, keepAlive) error {
+	return fmt.Errorf("TCP keep-alive options: %w", errors.ErrUnsupported)
+}
+
+func getKeepAlive(uintptr) (keepAlive, bool) {
+	return keepAlive{}, false
+}
+
+func closeOnExec(int) {}
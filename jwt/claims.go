@@ -0,0 +1,137 @@
+package jwt
+
+import (
+	"encoding/json"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Claims is the payload of a verified token. Numbers are stored as json.Number.
+// Use the typed accessors to read the claims, they return zero values if a claim is absent or has another type.
+type Claims map[string]any
+
+// Subject returns the "sub" claim.
+func (c Claims) Subject() string {
+	s, _ := c.String("sub")
+	return s
+}
+
+// Issuer returns the "iss" claim.
+func (c Claims) Issuer() string {
+	s, _ := c.String("iss")
+	return s
+}
+
+// Audience returns the "aud" claim, which can be a string or an array of strings.
+func (c Claims) Audience() []string {
+	s, _ := c.Strings("aud")
+	return s
+}
+
+// ID returns the "jti" claim.
+func (c Claims) ID() string {
+	s, _ := c.String("jti")
+	return s
+}
+
+// ExpiresAt returns the "exp" claim, it is zero if the token does not expire.
+func (c Claims) ExpiresAt() time.Time {
+	t, _ := c.Time("exp")
+	return t
+}
+
+// NotBefore returns the "nbf" claim.
+func (c Claims) NotBefore() time.Time {
+	t, _ := c.Time("nbf")
+	return t
+}
+
+// IssuedAt returns the "iat" claim.
+func (c Claims) IssuedAt() time.Time {
+	t, _ := c.Time("iat")
+	return t
+}
+
+// Scopes returns the space-separated "scope" claim, see RFC 8693.
+func (c Claims) Scopes() []string {
+	s, _ := c.String("scope")
+	return strings.Fields(s)
+}
+
+// HasScope reports whether the "scope" claim contains the scope.
+func (c Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes(), scope)
+}
+
+// String returns the claim if it is a string.
+func (c Claims) String(name string) (string, bool) {
+	s, ok := c[name].(string)
+	return s, ok
+}
+
+// Strings returns the claim if it is a string or an array of strings.
+func (c Claims) Strings(name string) ([]string, bool) {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}, true
+	case []any:
+		s := make([]string, 0, len(v))
+		for _, item := range v {
+			str, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			s = append(s, str)
+		}
+		return s, true
+	default:
+		return nil, false
+	}
+}
+
+// Int64 returns the claim if it is an integer number.
+func (c Claims) Int64(name string) (int64, bool) {
+	n, ok := c[name].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := n.Int64()
+	return i, err == nil
+}
+
+// Float64 returns the claim if it is a number.
+func (c Claims) Float64(name string) (float64, bool) {
+	n, ok := c[name].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := n.Float64()
+	return f, err == nil
+}
+
+// Bool returns the claim if it is a boolean.
+func (c Claims) Bool(name string) (bool, bool) {
+	b, ok := c[name].(bool)
+	return b, ok
+}
+
+// Time returns the claim if it is a NumericDate, the number of seconds since the Unix epoch.
+func (c Claims) Time(name string) (time.Time, bool) {
+	f, ok := c.Float64(name)
+	if !ok {
+		return time.Time{}, false
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)), true
+}
+
+// Decode decodes the claim into v, for example a struct for a nested object.
+func (c Claims) Decode(name string, v any) error {
+	data, err := json.Marshal(c[name])
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,229 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRefreshInterval    = time.Hour
+	defaultMinRefreshInterval = time.Minute
+	maxJWKSSize               = 1 << 20 // 1MiB
+)
+
+// JWKS is a KeySet that fetches the keys from a JSON Web Key Set URL, for example
+// https://issuer.example/.well-known/jwks.json, and caches them.
+//
+//	URL — URL of the JSON Web Key Set, it is required.
+//	Client — http client used to fetch the keys (http.DefaultClient by default).
+//	RefreshInterval — how long the keys are cached (1h by default).
+//	MinRefreshInterval — minimum time between fetches (1m by default).
+//
+// The keys are fetched on the first use and after RefreshInterval. A token with an unknown key ID causes a fetch,
+// so rotated keys are picked up before RefreshInterval, MinRefreshInterval prevents fetching on every such token.
+// If a fetch fails, the cached keys are used until the next one.
+type JWKS struct {
+	URL                string
+	Client             *http.Client
+	RefreshInterval    time.Duration
+	MinRefreshInterval time.Duration
+
+	fetchMu sync.Mutex
+
+	mu        sync.RWMutex
+	keys      StaticKeys
+	fetched   time.Time
+	attempted time.Time
+}
+
+// Keys returns the keys with the key ID, see StaticKeys.Keys.
+func (j *JWKS) Keys(ctx context.Context, kid string) ([]Key, error) {
+	j.mu.RLock()
+	keys, fetched, attempted := j.keys, j.fetched, j.attempted
+	j.mu.RUnlock()
+
+	found, _ := keys.Keys(ctx, kid)
+
+	now := time.Now()
+	stale := now.Sub(fetched) >= orDefault(j.RefreshInterval, defaultRefreshInterval)
+	if (!stale && len(found) != 0) || now.Sub(attempted) < orDefault(j.MinRefreshInterval, defaultMinRefreshInterval) {
+		return found, nil
+	}
+
+	if err := j.refresh(ctx, attempted); err != nil {
+		if len(found) == 0 {
+			return nil, err
+		}
+		slog.WarnContext(ctx, "refresh JWKS", "url", j.URL, "error", err)
+		return found, nil
+	}
+
+	j.mu.RLock()
+	keys = j.keys
+	j.mu.RUnlock()
+
+	return keys.Keys(ctx, kid)
+}
+
+// Refresh fetches the keys, it can be used to load them before serving requests.
+func (j *JWKS) Refresh(ctx context.Context) error {
+	j.mu.RLock()
+	attempted := j.attempted
+	j.mu.RUnlock()
+
+	return j.refresh(ctx, attempted)
+}
+
+// refresh fetches the keys unless they have been fetched by another goroutine since attempted.
+func (j *JWKS) refresh(ctx context.Context, attempted time.Time) error {
+	j.fetchMu.Lock()
+	defer j.fetchMu.Unlock()
+
+	j.mu.Lock()
+	if !j.attempted.Equal(attempted) {
+		j.mu.Unlock()
+		return nil
+	}
+	j.attempted = time.Now()
+	j.mu.Unlock()
+
+	keys, err := j.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	j.keys, j.fetched = keys, time.Now()
+	j.mu.Unlock()
+
+	return nil
+}
+
+func (j *JWKS) fetch(ctx context.Context) (StaticKeys, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	client := j.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var resp *http.Response
+	if resp, err = client.Do(req); err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch JWKS %s: %s", j.URL, resp.Status)
+	}
+
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err = json.NewDecoder(io.LimitReader(resp.Body, maxJWKSSize)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decode JWKS %s: %w", j.URL, err)
+	}
+
+	keys := make(StaticKeys, 0, len(set.Keys))
+	for _, raw := range set.Keys {
+		// keys of unsupported types are skipped, a set may contain keys for other purposes
+		if key, err := parseJWK(raw); err == nil {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w in JWKS %s", ErrNoKeys, j.URL)
+	}
+
+	return keys, nil
+}
+
+// jwk is a JSON Web Key, see RFC 7517 and RFC 8037.
+type jwk struct {
+	Type      string    `json:"kty"`
+	ID        string    `json:"kid"`
+	Algorithm Algorithm `json:"alg"`
+	Use       string    `json:"use"`
+	Curve     string    `json:"crv"`
+	N         string    `json:"n"`
+	E         string    `json:"e"`
+	X         string    `json:"x"`
+	Y         string    `json:"y"`
+	K         string    `json:"k"`
+}
+
+func parseJWK(data []byte) (Key, error) {
+	var k jwk
+	if err := json.Unmarshal(data, &k); err != nil {
+		return Key{}, err
+	}
+
+	if k.Use != "" && k.Use != "sig" {
+		return Key{}, fmt.Errorf("the key %q is not for signatures", k.ID)
+	}
+
+	key := Key{ID: k.ID, Algorithm: k.Algorithm}
+
+	switch {
+	case k.Type == "RSA":
+		n, e := decodeInt(k.N), decodeInt(k.E)
+		if n == nil || e == nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return Key{}, fmt.Errorf("invalid RSA key %q", k.ID)
+		}
+		key.Key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case k.Type == "EC" && k.Curve == "P-256":
+		x, y := decodeInt(k.X), decodeInt(k.Y)
+		if x == nil || y == nil || !elliptic.P256().IsOnCurve(x, y) {
+			return Key{}, fmt.Errorf("invalid EC key %q", k.ID)
+		}
+		key.Key = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+	case k.Type == "OKP" && k.Curve == "Ed25519":
+		x, err := encoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return Key{}, fmt.Errorf("invalid OKP key %q", k.ID)
+		}
+		key.Key = ed25519.PublicKey(x)
+	case k.Type == "oct":
+		secret, err := encoding.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return Key{}, fmt.Errorf("invalid oct key %q", k.ID)
+		}
+		key.Key = secret
+	default:
+		return Key{}, fmt.Errorf("unsupported key type %q", k.Type)
+	}
+
+	return key, nil
+}
+
+// decodeInt decodes a base64url encoded big-endian unsigned integer, it returns nil if the value is invalid.
+func decodeInt(s string) *big.Int {
+	b, err := encoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
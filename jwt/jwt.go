@@ -0,0 +1,268 @@
+package jwt
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformed            = errors.New("malformed token")
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrInvalidKey           = errors.New("the key cannot be used with the signing algorithm")
+	ErrKeyNotFound          = errors.New("no key to verify the token")
+	ErrInvalidSignature     = errors.New("invalid token signature")
+	ErrExpired              = errors.New("token is expired")
+	ErrNotValidYet          = errors.New("token is not valid yet")
+	ErrInvalidIssuer        = errors.New("invalid token issuer")
+	ErrInvalidAudience      = errors.New("invalid token audience")
+)
+
+// Algorithm is the JWS signing algorithm of a token.
+type Algorithm string
+
+const (
+	HS256 Algorithm = "HS256" // HMAC with SHA-256, the key is []byte.
+	RS256 Algorithm = "RS256" // RSASSA-PKCS1-v1_5 with SHA-256, the key is *rsa.PublicKey or *rsa.PrivateKey.
+	ES256 Algorithm = "ES256" // ECDSA with the P-256 curve and SHA-256, the key is *ecdsa.PublicKey or *ecdsa.PrivateKey.
+	EdDSA Algorithm = "EdDSA" // Ed25519, the key is ed25519.PublicKey or ed25519.PrivateKey.
+)
+
+var algorithms = []Algorithm{HS256, RS256, ES256, EdDSA}
+
+var encoding = base64.RawURLEncoding
+
+type header struct {
+	Algorithm Algorithm `json:"alg"`
+	Type      string    `json:"typ,omitempty"`
+	KeyID     string    `json:"kid,omitempty"`
+	Critical  []string  `json:"crit,omitempty"`
+}
+
+// Sign returns a signed token with the claims, which are encoded to JSON.
+// The key ID is added to the header if it is not empty.
+func Sign(alg Algorithm, kid string, claims any, key any) (string, error) {
+	h, err := json.Marshal(header{Algorithm: alg, Type: "JWT", KeyID: kid})
+	if err != nil {
+		return "", err
+	}
+
+	var payload []byte
+	if payload, err = json.Marshal(claims); err != nil {
+		return "", err
+	}
+
+	signingInput := encoding.EncodeToString(h) + "." + encoding.EncodeToString(payload)
+
+	var signature []byte
+	if signature, err = sign(alg, []byte(signingInput), key); err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + encoding.EncodeToString(signature), nil
+}
+
+func sign(alg Algorithm, data []byte, key any) ([]byte, error) {
+	hash := sha256.Sum256(data)
+
+	switch k := key.(type) {
+	case []byte:
+		if alg == HS256 {
+			mac := hmac.New(sha256.New, k)
+			mac.Write(data)
+			return mac.Sum(nil), nil
+		}
+	case *rsa.PrivateKey:
+		if alg == RS256 {
+			return rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, hash[:])
+		}
+	case *ecdsa.PrivateKey:
+		if alg == ES256 && k.Curve == elliptic.P256() {
+			r, s, err := ecdsa.Sign(rand.Reader, k, hash[:])
+			if err != nil {
+				return nil, err
+			}
+			signature := make([]byte, 64)
+			r.FillBytes(signature[:32])
+			s.FillBytes(signature[32:])
+			return signature, nil
+		}
+	case ed25519.PrivateKey:
+		if alg == EdDSA {
+			return ed25519.Sign(k, data), nil
+		}
+	}
+
+	if !slices.Contains(algorithms, alg) {
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedAlgorithm, alg)
+	}
+
+	return nil, fmt.Errorf("%w %s: %T", ErrInvalidKey, alg, key)
+}
+
+// verify reports whether the signature of the data is valid.
+func verify(alg Algorithm, data, signature []byte, key any) bool {
+	hash := sha256.Sum256(data)
+
+	switch k := key.(type) {
+	case []byte:
+		if alg == HS256 {
+			mac := hmac.New(sha256.New, k)
+			mac.Write(data)
+			return hmac.Equal(signature, mac.Sum(nil))
+		}
+	case *rsa.PublicKey:
+		if alg == RS256 {
+			return rsa.VerifyPKCS1v15(k, crypto.SHA256, hash[:], signature) == nil
+		}
+	case *ecdsa.PublicKey:
+		if alg == ES256 && k.Curve == elliptic.P256() && len(signature) == 64 {
+			r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+			return ecdsa.Verify(k, hash[:], r, s)
+		}
+	case ed25519.PublicKey:
+		if alg == EdDSA {
+			return ed25519.Verify(k, data, signature)
+		}
+	}
+
+	return false
+}
+
+// Verifier verifies tokens and their claims.
+//
+//	Keys — KeySet with the keys the tokens are signed with, it is required.
+//	Algorithms — algorithms that are accepted (HS256, RS256, ES256 and EdDSA by default).
+//	Issuer — expected "iss" claim, it is not checked if empty.
+//	Audience — value that the "aud" claim must contain, it is not checked if empty.
+//	ClockSkew — allowed difference between the clocks of the issuer and the Verifier for "exp" and "nbf".
+//
+// The "exp" and "nbf" claims are checked if the token has them.
+// A key is used only with the algorithm it is intended for, so a public key cannot be used as an HMAC secret.
+type Verifier struct {
+	Keys       KeySet
+	Algorithms []Algorithm
+	Issuer     string
+	Audience   string
+	ClockSkew  time.Duration
+}
+
+// Verify checks the signature and the claims of the token and returns the claims.
+func (v *Verifier) Verify(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, err
+	}
+
+	allowed := v.Algorithms
+	if len(allowed) == 0 {
+		allowed = algorithms
+	}
+	if !slices.Contains(allowed, h.Algorithm) || !slices.Contains(algorithms, h.Algorithm) {
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedAlgorithm, h.Algorithm)
+	}
+	if len(h.Critical) != 0 {
+		return nil, fmt.Errorf("%w: unsupported critical header parameters %q", ErrMalformed, h.Critical)
+	}
+
+	signature, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	var keys []Key
+	if keys, err = v.Keys.Keys(ctx, h.KeyID); err != nil {
+		return nil, err
+	}
+
+	data := []byte(parts[0] + "." + parts[1])
+
+	verified, found := false, false
+	for _, key := range keys {
+		if key.Algorithm != "" && key.Algorithm != h.Algorithm {
+			continue
+		}
+		found = true
+		if verified = verify(h.Algorithm, data, signature, key.Key); verified {
+			break
+		}
+	}
+	if !found {
+		return nil, ErrKeyNotFound
+	}
+	if !verified {
+		return nil, ErrInvalidSignature
+	}
+
+	var claims Claims
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if claims == nil {
+		return nil, ErrMalformed
+	}
+
+	if err = v.validate(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (v *Verifier) validate(claims Claims) error {
+	now := time.Now()
+
+	if exp, ok := claims.Time("exp"); ok && !now.Before(exp.Add(v.ClockSkew)) {
+		return ErrExpired
+	}
+
+	if nbf, ok := claims.Time("nbf"); ok && now.Add(v.ClockSkew).Before(nbf) {
+		return ErrNotValidYet
+	}
+
+	if v.Issuer != "" && claims.Issuer() != v.Issuer {
+		return ErrInvalidIssuer
+	}
+
+	if v.Audience != "" && !slices.Contains(claims.Audience(), v.Audience) {
+		return ErrInvalidAudience
+	}
+
+	return nil
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of the token, numbers are decoded as json.Number.
+func decodeSegment(segment string, v any) error {
+	data, err := encoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err = dec.Decode(v); err != nil {
+		return fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,329 @@
+package jwt_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/easy-techno-lab/proton/jwt"
+	"github.com/easy-techno-lab/proton/tlscert"
+)
+
+func equal(t *testing.T, exp, got any) {
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("Not equal:\nexp: %v\ngot: %v", exp, got)
+	}
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	secret := []byte("secret")
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	equal(t, nil, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	equal(t, nil, err)
+
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	equal(t, nil, err)
+
+	keys := jwt.StaticKeys{
+		{ID: "hs", Algorithm: jwt.HS256, Key: secret},
+		{ID: "rs", Key: &rsaKey.PublicKey},
+		{ID: "es", Key: &ecKey.PublicKey},
+		{ID: "ed", Key: edPublic},
+	}
+
+	now := time.Now().Unix()
+
+	valid := jwt.Claims{"sub": "user", "iss": "issuer", "aud": []string{"api", "other"}, "exp": now + 60}
+
+	var tests = []struct {
+		name   string
+		alg    jwt.Algorithm
+		kid    string
+		key    any
+		claims jwt.Claims
+		token  string
+		err    error
+	}{
+		{name: "HS256", alg: jwt.HS256, kid: "hs", key: secret, claims: valid},
+		{name: "RS256", alg: jwt.RS256, kid: "rs", key: rsaKey, claims: valid},
+		{name: "ES256", alg: jwt.ES256, kid: "es", key: ecKey, claims: valid},
+		{name: "EdDSA", alg: jwt.EdDSA, kid: "ed", key: edKey, claims: valid},
+		{
+			name:   "single audience",
+			alg:    jwt.HS256,
+			kid:    "hs",
+			key:    secret,
+			claims: jwt.Claims{"iss": "issuer", "aud": "api"},
+		},
+		{
+			name:   "expired within the clock skew",
+			alg:    jwt.HS256,
+			kid:    "hs",
+			key:    secret,
+			claims: jwt.Claims{"iss": "issuer", "aud": "api", "exp": now - 10},
+		},
+		{
+			name:   "expired",
+			alg:    jwt.HS256,
+			kid:    "hs",
+			key:    secret,
+			claims: jwt.Claims{"iss": "issuer", "aud": "api", "exp": now - 60},
+			err:    jwt.ErrExpired,
+		},
+		{
+			name:   "not valid yet",
+			alg:    jwt.HS256,
+			kid:    "hs",
+			key:    secret,
+			claims: jwt.Claims{"iss": "issuer", "aud": "api", "nbf": now + 60},
+			err:    jwt.ErrNotValidYet,
+		},
+		{
+			name:   "invalid issuer",
+			alg:    jwt.HS256,
+			kid:    "hs",
+			key:    secret,
+			claims: jwt.Claims{"iss": "other", "aud": "api"},
+			err:    jwt.ErrInvalidIssuer,
+		},
+		{
+			name:   "invalid audience",
+			alg:    jwt.HS256,
+			kid:    "hs",
+			key:    secret,
+			claims: jwt.Claims{"iss": "issuer", "aud": "other"},
+			err:    jwt.ErrInvalidAudience,
+		},
+		{
+			name:   "wrong key",
+			alg:    jwt.HS256,
+			kid:    "hs",
+			key:    []byte("other"),
+			claims: valid,
+			err:    jwt.ErrInvalidSignature,
+		},
+		{
+			name:   "unknown key ID",
+			alg:    jwt.EdDSA,
+			kid:    "unknown",
+			key:    edKey,
+			claims: valid,
+			err:    jwt.ErrKeyNotFound,
+		},
+		{
+			name:   "key of another algorithm",
+			alg:    jwt.HS256,
+			kid:    "rs",
+			key:    secret,
+			claims: valid,
+			err:    jwt.ErrInvalidSignature,
+		},
+		{
+			name:  "none algorithm",
+			token: "eyJhbGciOiJub25lIn0.eyJzdWIiOiJ1c2VyIn0.",
+			err:   jwt.ErrUnsupportedAlgorithm,
+		},
+		{
+			name:  "malformed",
+			token: "not a token",
+			err:   jwt.ErrMalformed,
+		},
+	}
+
+	v := &jwt.Verifier{Keys: keys, Issuer: "issuer", Audience: "api", ClockSkew: 30 * time.Second}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token := test.token
+			if token == "" {
+				token, err = jwt.Sign(test.alg, test.kid, test.claims, test.key)
+				equal(t, nil, err)
+			}
+
+			claims, err := v.Verify(context.Background(), token)
+			equal(t, true, errors.Is(err, test.err))
+			if test.err == nil {
+				equal(t, test.claims["sub"] != nil, claims.Subject() != "")
+				equal(t, "issuer", claims.Issuer())
+			}
+		})
+	}
+}
+
+func TestSign_InvalidKey(t *testing.T) {
+	_, err := jwt.Sign(jwt.RS256, "", jwt.Claims{}, []byte("secret"))
+	equal(t, true, errors.Is(err, jwt.ErrInvalidKey))
+
+	_, err = jwt.Sign("none", "", jwt.Claims{}, []byte("secret"))
+	equal(t, true, errors.Is(err, jwt.ErrUnsupportedAlgorithm))
+}
+
+func TestClaims(t *testing.T) {
+	token, err := jwt.Sign(jwt.HS256, "", map[string]any{
+		"sub":   "user",
+		"aud":   "api",
+		"exp":   4102444800,
+		"scope": "read write",
+		"admin": true,
+		"level": 3,
+		"roles": []string{"a", "b"},
+		"org":   map[string]string{"id": "42"},
+	}, []byte("secret"))
+	equal(t, nil, err)
+
+	v := &jwt.Verifier{Keys: jwt.HMACKey("", []byte("secret"))}
+	claims, err := v.Verify(context.Background(), token)
+	equal(t, nil, err)
+
+	equal(t, "user", claims.Subject())
+	equal(t, []string{"api"}, claims.Audience())
+	equal(t, time.Unix(4102444800, 0), claims.ExpiresAt())
+	equal(t, time.Time{}, claims.NotBefore())
+	equal(t, []string{"read", "write"}, claims.Scopes())
+	equal(t, true, claims.HasScope("write"))
+	equal(t, false, claims.HasScope("delete"))
+
+	admin, ok := claims.Bool("admin")
+	equal(t, true, ok && admin)
+
+	level, ok := claims.Int64("level")
+	equal(t, true, ok)
+	equal(t, int64(3), level)
+
+	roles, ok := claims.Strings("roles")
+	equal(t, true, ok)
+	equal(t, []string{"a", "b"}, roles)
+
+	_, ok = claims.String("level")
+	equal(t, false, ok)
+
+	var org struct {
+		ID string `json:"id"`
+	}
+	equal(t, nil, claims.Decode("org", &org))
+	equal(t, "42", org.ID)
+}
+
+func TestKeysFromLoader(t *testing.T) {
+	loader := &tlscert.Loader{
+		Template: &x509.Certificate{
+			Subject:   pkix.Name{CommonName: "issuer"},
+			NotBefore: time.Now().Add(-time.Hour),
+			NotAfter:  time.Now().Add(time.Hour),
+		},
+		KeyAlgorithm: tlscert.Ed25519,
+	}
+
+	certificates, _, err := loader.LoadGenerated()
+	equal(t, nil, err)
+
+	keys, err := jwt.KeysFromLoader(func() ([]tls.Certificate, *x509.CertPool, error) {
+		return certificates, nil, nil
+	})
+	equal(t, nil, err)
+	equal(t, 1, len(keys))
+
+	token, err := jwt.Sign(jwt.EdDSA, "any", jwt.Claims{"sub": "user"}, certificates[0].PrivateKey)
+	equal(t, nil, err)
+
+	_, err = (&jwt.Verifier{Keys: keys}).Verify(context.Background(), token)
+	equal(t, nil, err)
+
+	// the same key from the PEM encoded certificate
+	keys, err = jwt.KeysFromPEM(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificates[0].Certificate[0]}))
+	equal(t, nil, err)
+
+	_, err = (&jwt.Verifier{Keys: keys}).Verify(context.Background(), token)
+	equal(t, nil, err)
+
+	_, err = jwt.KeysFromPEM([]byte("no keys"))
+	equal(t, true, errors.Is(err, jwt.ErrNoKeys))
+}
+
+func TestJWKS(t *testing.T) {
+	public1, key1, err := ed25519.GenerateKey(rand.Reader)
+	equal(t, nil, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	equal(t, nil, err)
+
+	jwk := func(kid string, public ed25519.PublicKey) map[string]string {
+		return map[string]string{"kty": "OKP", "crv": "Ed25519", "kid": kid, "x": base64.RawURLEncoding.EncodeToString(public)}
+	}
+
+	set := []map[string]string{
+		jwk("1", public1),
+		{
+			"kty": "EC",
+			"crv": "P-256",
+			"kid": "ec",
+			"x":   base64.RawURLEncoding.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(ecKey.Y.FillBytes(make([]byte, 32))),
+		},
+		{"kty": "unknown", "kid": "skipped"},
+	}
+
+	var fetches atomic.Int32
+	var current atomic.Value
+	current.Store(set)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": current.Load()})
+	}))
+	defer srv.Close()
+
+	jwks := &jwt.JWKS{URL: srv.URL, MinRefreshInterval: 50 * time.Millisecond}
+	v := &jwt.Verifier{Keys: jwks}
+
+	verify := func(kid string, alg jwt.Algorithm, key any) error {
+		token, err := jwt.Sign(alg, kid, jwt.Claims{"sub": "user"}, key)
+		equal(t, nil, err)
+		_, err = v.Verify(context.Background(), token)
+		return err
+	}
+
+	equal(t, nil, verify("1", jwt.EdDSA, key1))
+	equal(t, nil, verify("ec", jwt.ES256, ecKey))
+	equal(t, int32(1), fetches.Load())
+
+	// the key is rotated
+	public2, key2, err := ed25519.GenerateKey(rand.Reader)
+	equal(t, nil, err)
+	current.Store([]map[string]string{jwk("2", public2)})
+
+	// unknown key IDs do not cause a fetch more often than MinRefreshInterval
+	equal(t, true, errors.Is(verify("2", jwt.EdDSA, key2), jwt.ErrKeyNotFound))
+	equal(t, int32(1), fetches.Load())
+
+	time.Sleep(60 * time.Millisecond)
+
+	equal(t, nil, verify("2", jwt.EdDSA, key2))
+	equal(t, int32(2), fetches.Load())
+
+	// the cached keys are used if the server fails
+	srv.Close()
+	time.Sleep(60 * time.Millisecond)
+
+	equal(t, nil, verify("2", jwt.EdDSA, key2))
+	equal(t, true, verify("1", jwt.EdDSA, key1) != nil)
+}
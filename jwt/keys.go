@@ -0,0 +1,121 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+
+	"github.com/easy-techno-lab/proton/tlscert"
+)
+
+var ErrNoKeys = errors.New("no public keys found")
+
+// Key is a key that verifies tokens.
+//
+//	ID — key ID matched against the "kid" header, a key with an empty ID matches any token.
+//	Algorithm — algorithm the key is used with, any algorithm that suits the type of the key if empty.
+//	Key — []byte secret for HS256, or *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey.
+type Key struct {
+	ID        string
+	Algorithm Algorithm
+	Key       any
+}
+
+// KeySet provides the keys to verify tokens. It must be safe for concurrent use.
+type KeySet interface {
+	// Keys returns the keys that match the key ID from the token header, kid is empty if the header has none.
+	Keys(ctx context.Context, kid string) ([]Key, error)
+}
+
+// StaticKeys is a KeySet with a fixed list of keys.
+type StaticKeys []Key
+
+// Keys returns the keys with the key ID or with an empty ID, all keys are returned if kid is empty.
+func (s StaticKeys) Keys(_ context.Context, kid string) ([]Key, error) {
+	if kid == "" {
+		return s, nil
+	}
+
+	var keys []Key
+	for _, key := range s {
+		if key.ID == "" || key.ID == kid {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// HMACKey returns a StaticKeys with the HS256 secret.
+func HMACKey(kid string, secret []byte) StaticKeys {
+	return StaticKeys{{ID: kid, Algorithm: HS256, Key: secret}}
+}
+
+// KeysFromLoader returns the public keys of the certificates returned by the tlscert loader,
+// for example tlscert.Loader.LoadFromFiles. The keys have empty IDs.
+func KeysFromLoader(loader tlscert.CertificatesLoader) (StaticKeys, error) {
+	certificates, _, err := loader()
+	if err != nil {
+		return nil, err
+	}
+
+	var keys StaticKeys
+	for _, certificate := range certificates {
+		leaf := certificate.Leaf
+		if leaf == nil {
+			if len(certificate.Certificate) == 0 {
+				continue
+			}
+			if leaf, err = x509.ParseCertificate(certificate.Certificate[0]); err != nil {
+				return nil, err
+			}
+		}
+		keys = append(keys, Key{Key: leaf.PublicKey})
+	}
+
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+
+	return keys, nil
+}
+
+// KeysFromPEM returns the public keys from the PEM encoded CERTIFICATE and PUBLIC KEY blocks.
+// Other blocks are skipped. The keys have empty IDs.
+func KeysFromPEM(data []byte) (StaticKeys, error) {
+	var keys StaticKeys
+
+	for {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			break
+		}
+
+		var key crypto.PublicKey
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			key = cert.PublicKey
+		case "PUBLIC KEY":
+			var err error
+			if key, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+				return nil, err
+			}
+		default:
+			continue
+		}
+
+		keys = append(keys, Key{Key: key})
+	}
+
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+
+	return keys, nil
+}
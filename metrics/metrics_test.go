@@ -0,0 +1,111 @@
+package metrics_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/easy-techno-lab/proton/metrics"
+)
+
+func equal(t *testing.T, exp, got any) {
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("Not equal:\nexp: %v\ngot: %v", exp, got)
+	}
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	reg := metrics.NewRegistry()
+
+	requests := reg.Counter("requests_total", "Total requests.\nWith a new line.", "method", "path")
+	requests.With("GET", `/a"b\`).Inc()
+	requests.With("GET", "/").Add(2)
+	requests.With("GET", "/").Add(-1)
+
+	// get-or-create returns the same family
+	reg.Counter("requests_total", "", "method", "path").With("GET", "/").Inc()
+
+	inFlight := reg.Gauge("in_flight", "")
+	inFlight.With().Inc()
+	inFlight.With().Inc()
+	inFlight.With().Dec()
+
+	duration := reg.Histogram("duration_seconds", "Duration.", []float64{0.1, 1}, "code")
+	duration.With("200").Observe(0.05)
+	duration.With("200").Observe(0.5)
+	duration.With("200").Observe(5)
+
+	reg.Gauge("empty", "No series.")
+
+	srv := httptest.NewServer(reg.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	equal(t, nil, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	equal(t, nil, err)
+
+	equal(t, metrics.ContentType, resp.Header.Get("Content-Type"))
+	equal(t, strings.Join([]string{
+		`# HELP duration_seconds Duration.`,
+		`# TYPE duration_seconds histogram`,
+		`duration_seconds_bucket{code="200",le="0.1"} 1`,
+		`duration_seconds_bucket{code="200",le="1"} 2`,
+		`duration_seconds_bucket{code="200",le="+Inf"} 3`,
+		`duration_seconds_sum{code="200"} 5.55`,
+		`duration_seconds_count{code="200"} 3`,
+		`# TYPE in_flight gauge`,
+		`in_flight 1`,
+		`# HELP requests_total Total requests.\nWith a new line.`,
+		`# TYPE requests_total counter`,
+		`requests_total{method="GET",path="/"} 3`,
+		`requests_total{method="GET",path="/a\"b\\"} 1`,
+		``,
+	}, "\n"), string(body))
+}
+
+func TestRegistry_Panics(t *testing.T) {
+	var tests = []struct {
+		name string
+		f    func(reg *metrics.Registry)
+	}{
+		{
+			name: "invalid name",
+			f:    func(reg *metrics.Registry) { reg.Counter("1requests", "") },
+		},
+		{
+			name: "invalid label",
+			f:    func(reg *metrics.Registry) { reg.Counter("requests", "", "a-b") },
+		},
+		{
+			name: "le label of histogram",
+			f:    func(reg *metrics.Registry) { reg.Histogram("duration", "", nil, "le") },
+		},
+		{
+			name: "different type",
+			f:    func(reg *metrics.Registry) { reg.Counter("requests", ""); reg.Gauge("requests", "") },
+		},
+		{
+			name: "different labels",
+			f:    func(reg *metrics.Registry) { reg.Counter("requests", "", "a"); reg.Counter("requests", "", "b") },
+		},
+		{
+			name: "wrong number of label values",
+			f:    func(reg *metrics.Registry) { reg.Counter("requests", "", "a").With("1", "2") },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				equal(t, true, recover() != nil)
+			}()
+			test.f(metrics.NewRegistry())
+		})
+	}
+}
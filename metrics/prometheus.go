@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the content type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Handler returns an http.Handler which serves the metrics of the Registry in the Prometheus text exposition format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		if _, err := r.WriteTo(w); err != nil {
+			slog.ErrorContext(req.Context(), "write metrics", "error", err)
+		}
+	})
+}
+
+// WriteTo writes the metrics of the Registry in the Prometheus text exposition format.
+// Families and series are sorted by names and label values.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.RUnlock()
+
+	slices.SortFunc(families, func(a, b *family) int { return strings.Compare(a.name, b.name) })
+
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+
+	for _, f := range families {
+		f.write(bw)
+	}
+
+	err := bw.Flush()
+
+	return cw.n, err
+}
+
+func (f *family) write(w *bufio.Writer) {
+	f.mu.RLock()
+	all := make([]*series, 0, len(f.series))
+	for _, s := range f.series {
+		all = append(all, s)
+	}
+	f.mu.RUnlock()
+
+	if len(all) == 0 {
+		return
+	}
+
+	slices.SortFunc(all, func(a, b *series) int { return slices.Compare(a.values, b.values) })
+
+	if f.help != "" {
+		_, _ = w.WriteString("# HELP " + f.name + " ")
+		_, _ = w.WriteString(helpReplacer.Replace(f.help))
+		_ = w.WriteByte('\n')
+	}
+	_, _ = w.WriteString("# TYPE " + f.name + " " + f.typ.String() + "\n")
+
+	for _, s := range all {
+		if f.typ != histogramType {
+			f.sample(w, "", s.values, "", s.value.load())
+			continue
+		}
+
+		var cumulative uint64
+		for i, bound := range f.buckets {
+			cumulative += s.counts[i].Load()
+			f.sample(w, "_bucket", s.values, formatFloat(bound), float64(cumulative))
+		}
+
+		count := s.count.Load()
+		f.sample(w, "_bucket", s.values, "+Inf", float64(count))
+		f.sample(w, "_sum", s.values, "", s.value.load())
+		f.sample(w, "_count", s.values, "", float64(count))
+	}
+}
+
+// sample writes a line of the metric, le is the label of a histogram bucket.
+func (f *family) sample(w *bufio.Writer, suffix string, values []string, le string, v float64) {
+	_, _ = w.WriteString(f.name + suffix)
+
+	if len(values) != 0 || le != "" {
+		_ = w.WriteByte('{')
+		for i, label := range f.labels {
+			if i > 0 {
+				_ = w.WriteByte(',')
+			}
+			_, _ = w.WriteString(label + `="` + valueReplacer.Replace(values[i]) + `"`)
+		}
+		if le != "" {
+			if len(values) != 0 {
+				_ = w.WriteByte(',')
+			}
+			_, _ = w.WriteString(`le="` + le + `"`)
+		}
+		_ = w.WriteByte('}')
+	}
+
+	_ = w.WriteByte(' ')
+	_, _ = w.WriteString(formatFloat(v))
+	_ = w.WriteByte('\n')
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	valueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, +1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(b []byte) (int, error) {
+	n, err := cw.w.Write(b)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,331 @@
+package problem
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	ContentType    = "application/problem+json"
+	ContentTypeXML = "application/problem+xml"
+
+	namespaceXML = "urn:ietf:rfc:7807"
+	maxBody      = 1 << 16 // 64KiB
+)
+
+// The error hierarchy: every Details matches the sentinel with the same status code,
+// and ErrClient or ErrServer according to the status code class.
+// Use the sentinels only as targets of errors.Is, create new errors with New or Wrap.
+// From and With return copies of the sentinels, so the sentinels are never modified by them.
+var (
+	ErrClient = &Details{class: 4, sentinel: true}
+	ErrServer = &Details{class: 5, sentinel: true}
+
+	ErrBadRequest           = newSentinel(http.StatusBadRequest)
+	ErrUnauthorized         = newSentinel(http.StatusUnauthorized)
+	ErrForbidden            = newSentinel(http.StatusForbidden)
+	ErrNotFound             = newSentinel(http.StatusNotFound)
+	ErrMethodNotAllowed     = newSentinel(http.StatusMethodNotAllowed)
+	ErrNotAcceptable        = newSentinel(http.StatusNotAcceptable)
+	ErrConflict             = newSentinel(http.StatusConflict)
+	ErrGone                 = newSentinel(http.StatusGone)
+	ErrUnsupportedMediaType = newSentinel(http.StatusUnsupportedMediaType)
+	ErrUnprocessableEntity  = newSentinel(http.StatusUnprocessableEntity)
+	ErrTooManyRequests      = newSentinel(http.StatusTooManyRequests)
+	ErrInternal             = newSentinel(http.StatusInternalServerError)
+	ErrNotImplemented       = newSentinel(http.StatusNotImplemented)
+	ErrBadGateway           = newSentinel(http.StatusBadGateway)
+	ErrServiceUnavailable   = newSentinel(http.StatusServiceUnavailable)
+	ErrGatewayTimeout       = newSentinel(http.StatusGatewayTimeout)
+)
+
+func newSentinel(status int) *Details {
+	d := New(status, "")
+	d.sentinel = true
+	return d
+}
+
+// Details is an RFC 9457 problem details object. It is used as an error which carries the HTTP status code.
+//
+//	Type — URI reference that identifies the problem type, "about:blank" if empty.
+//	Title — short human-readable summary of the problem type.
+//	Status — HTTP status code.
+//	Detail — human-readable explanation specific to this occurrence of the problem.
+//	Instance — URI reference that identifies the specific occurrence of the problem.
+//	Extensions — additional members of the problem details object.
+type Details struct {
+	Type       string         `xml:"type,omitempty"`
+	Title      string         `xml:"title,omitempty"`
+	Status     int            `xml:"status,omitempty"`
+	Detail     string         `xml:"detail,omitempty"`
+	Instance   string         `xml:"instance,omitempty"`
+	Extensions map[string]any `xml:"-"`
+
+	err      error
+	class    int
+	sentinel bool
+}
+
+// New returns a new Details with the status code, its text as the title and the detail.
+func New(status int, detail string) *Details {
+	return &Details{Title: http.StatusText(status), Status: status, Detail: detail}
+}
+
+// Wrap returns a new Details with the status code and the error message as the detail.
+// The error can be retrieved with errors.Unwrap.
+func Wrap(status int, err error) *Details {
+	d := New(status, err.Error())
+	d.err = err
+	return d
+}
+
+// From converts the error into Details.
+// An error that contains Details is returned as is, a sentinel or Details without the status code is returned
+// as a copy wrapping the error with the status code of its class, 500 Internal Server Error if it has none.
+// An error implementing the StatusCode() int method gets its status code,
+// its message is used as the detail for client errors only.
+// Any other error becomes 500 Internal Server Error without the detail.
+func From(err error) *Details {
+	var d *Details
+	if errors.As(err, &d) {
+		if !d.sentinel && d.Status != 0 {
+			return d
+		}
+
+		c := d.clone()
+		if c.Status == 0 {
+			c.Status = http.StatusInternalServerError
+			if d.class != 0 {
+				c.Status = d.class * 100
+			}
+			c.Title = http.StatusText(c.Status)
+		}
+		c.err = err
+		return c
+	}
+
+	var sc interface{ StatusCode() int }
+	if errors.As(err, &sc) {
+		if status := sc.StatusCode(); status < http.StatusInternalServerError {
+			return Wrap(status, err)
+		}
+		d = New(sc.StatusCode(), "")
+	} else {
+		d = New(http.StatusInternalServerError, "")
+	}
+
+	d.err = err
+	return d
+}
+
+// With sets the extension member and returns the Details, for a sentinel it returns a copy with the member.
+func (d *Details) With(key string, value any) *Details {
+	if d.sentinel {
+		d = d.clone()
+	}
+	if d.Extensions == nil {
+		d.Extensions = make(map[string]any)
+	}
+	d.Extensions[key] = value
+	return d
+}
+
+// clone returns a copy of the Details that is not a sentinel.
+func (d *Details) clone() *Details {
+	c := *d
+	c.class, c.sentinel = 0, false
+	if d.Extensions != nil {
+		c.Extensions = make(map[string]any, len(d.Extensions))
+		for key, value := range d.Extensions {
+			c.Extensions[key] = value
+		}
+	}
+	return &c
+}
+
+func (d *Details) Error() string {
+	s := fmt.Sprintf("%d %s", d.Status, d.Title)
+	if d.Detail != "" {
+		s += ": " + d.Detail
+	}
+	return s
+}
+
+// StatusCode returns the HTTP status code.
+func (d *Details) StatusCode() int {
+	return d.Status
+}
+
+func (d *Details) Unwrap() error {
+	return d.err
+}
+
+// Is reports whether the Details matches the target: by the status code class for ErrClient and ErrServer,
+// otherwise by the status code and, if the target has one, by the type.
+func (d *Details) Is(target error) bool {
+	t, ok := target.(*Details)
+	if !ok {
+		return false
+	}
+
+	if t.class != 0 {
+		return d.Status/100 == t.class
+	}
+
+	if t.Type != "" && t.Type != d.Type {
+		return false
+	}
+
+	return t.Status == d.Status
+}
+
+// MarshalJSON encodes the Details with the extension members at the top level.
+func (d *Details) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(d.Extensions)+5)
+	for key, value := range d.Extensions {
+		m[key] = value
+	}
+	if d.Type != "" {
+		m["type"] = d.Type
+	}
+	if d.Title != "" {
+		m["title"] = d.Title
+	}
+	if d.Status != 0 {
+		m["status"] = d.Status
+	}
+	if d.Detail != "" {
+		m["detail"] = d.Detail
+	}
+	if d.Instance != "" {
+		m["instance"] = d.Instance
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON decodes the Details, unknown members are stored in the Extensions.
+func (d *Details) UnmarshalJSON(data []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	members := map[string]any{
+		"type":     &d.Type,
+		"title":    &d.Title,
+		"status":   &d.Status,
+		"detail":   &d.Detail,
+		"instance": &d.Instance,
+	}
+
+	for key, raw := range m {
+		if v, ok := members[key]; ok {
+			// members of a wrong type must be ignored
+			_ = json.Unmarshal(raw, v)
+			continue
+		}
+
+		var v any
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+		d.With(key, v)
+	}
+
+	return nil
+}
+
+// MarshalXML encodes the Details in the RFC 9457 XML format, extension members are encoded as text elements.
+func (d *Details) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	start := xml.StartElement{Name: xml.Name{Space: namespaceXML, Local: "problem"}}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	element := func(name, value string) error {
+		if value == "" {
+			return nil
+		}
+		return e.EncodeElement(value, xml.StartElement{Name: xml.Name{Local: name}})
+	}
+
+	status := ""
+	if d.Status != 0 {
+		status = fmt.Sprint(d.Status)
+	}
+
+	for _, member := range [][2]string{
+		{"type", d.Type}, {"title", d.Title}, {"status", status}, {"detail", d.Detail}, {"instance", d.Instance},
+	} {
+		if err := element(member[0], member[1]); err != nil {
+			return err
+		}
+	}
+
+	keys := make([]string, 0, len(d.Extensions))
+	for key := range d.Extensions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := element(key, fmt.Sprint(d.Extensions[key])); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// ContentTypeFor returns the problem details content type matching the content type of a Coder:
+// application/problem+json for JSON, application/problem+xml for XML and the content type itself otherwise.
+func ContentTypeFor(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return ContentType
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return ContentTypeXML
+	default:
+		return contentType
+	}
+}
+
+// FromResponse reads the response body and returns it as Details.
+// JSON and XML bodies are decoded as problem details, other bodies are used as the detail.
+// The status code and title are taken from the response if the body does not contain them.
+func FromResponse(r *http.Response) *Details {
+	body, _ := io.ReadAll(io.LimitReader(r.Body, maxBody))
+
+	d := new(Details)
+
+	switch ContentTypeFor(r.Header.Get("Content-Type")) {
+	case ContentType:
+		if err := json.Unmarshal(body, d); err != nil {
+			d = &Details{Detail: string(bytes.TrimSpace(body))}
+		}
+	case ContentTypeXML:
+		if err := xml.Unmarshal(body, d); err != nil {
+			d = &Details{Detail: string(bytes.TrimSpace(body))}
+		}
+	default:
+		d.Detail = string(bytes.TrimSpace(body))
+	}
+
+	if d.Status == 0 {
+		d.Status = r.StatusCode
+	}
+	if d.Title == "" {
+		d.Title = http.StatusText(d.Status)
+	}
+
+	return d
+}
@@ -0,0 +1,167 @@
+package problem_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/easy-techno-lab/proton/problem"
+)
+
+func equal(t *testing.T, exp, got any) {
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("Not equal:\nexp: %v\ngot: %v", exp, got)
+	}
+}
+
+type statusError int
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("status %d", int(e))
+}
+
+func (e statusError) StatusCode() int {
+	return int(e)
+}
+
+func TestDetails_Is(t *testing.T) {
+	var tests = []struct {
+		name   string
+		err    error
+		target error
+		exp    bool
+	}{
+		{
+			name:   "same status",
+			err:    problem.New(http.StatusNotFound, "user not found"),
+			target: problem.ErrNotFound,
+			exp:    true,
+		},
+		{
+			name:   "wrapped",
+			err:    fmt.Errorf("get user: %w", problem.New(http.StatusNotFound, "")),
+			target: problem.ErrNotFound,
+			exp:    true,
+		},
+		{
+			name:   "other status",
+			err:    problem.New(http.StatusConflict, ""),
+			target: problem.ErrNotFound,
+		},
+		{
+			name:   "client class",
+			err:    problem.New(http.StatusConflict, ""),
+			target: problem.ErrClient,
+			exp:    true,
+		},
+		{
+			name:   "server class",
+			err:    problem.New(http.StatusConflict, ""),
+			target: problem.ErrServer,
+		},
+		{
+			name:   "type mismatch",
+			err:    &problem.Details{Type: "https://example.com/a", Status: http.StatusBadRequest},
+			target: &problem.Details{Type: "https://example.com/b", Status: http.StatusBadRequest},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			equal(t, test.exp, errors.Is(test.err, test.target))
+		})
+	}
+}
+
+func TestFrom(t *testing.T) {
+	cause := errors.New("database is down")
+
+	var tests = []struct {
+		name   string
+		err    error
+		status int
+		detail string
+	}{
+		{
+			name:   "details",
+			err:    problem.New(http.StatusGone, "removed"),
+			status: http.StatusGone,
+			detail: "removed",
+		},
+		{
+			name:   "status coder",
+			err:    statusError(http.StatusUnprocessableEntity),
+			status: http.StatusUnprocessableEntity,
+			detail: "status 422",
+		},
+		{
+			name:   "server status coder hides the message",
+			err:    statusError(http.StatusServiceUnavailable),
+			status: http.StatusServiceUnavailable,
+		},
+		{
+			name:   "unknown error",
+			err:    cause,
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:   "sentinel",
+			err:    problem.ErrNotFound,
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "client class sentinel",
+			err:    problem.ErrClient,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "wrapped server class sentinel",
+			err:    fmt.Errorf("call upstream: %w", problem.ErrServer),
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:   "details without the status code",
+			err:    &problem.Details{Detail: "unknown"},
+			status: http.StatusInternalServerError,
+			detail: "unknown",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := problem.From(test.err)
+			equal(t, test.status, d.Status)
+			equal(t, test.detail, d.Detail)
+			equal(t, true, errors.Is(d, test.err))
+		})
+	}
+}
+
+func TestSentinels_NotModified(t *testing.T) {
+	d := problem.From(fmt.Errorf("get user: %w", problem.ErrNotFound)).With("id", 7)
+	d.Detail = "user not found"
+
+	notFound := problem.ErrNotFound.With("id", 8)
+
+	equal(t, map[string]any(nil), problem.ErrNotFound.Extensions)
+	equal(t, "", problem.ErrNotFound.Detail)
+	equal(t, map[string]any{"id": 8}, notFound.Extensions)
+	equal(t, true, errors.Is(notFound, problem.ErrNotFound))
+}
+
+func TestDetails_JSON(t *testing.T) {
+	d := problem.New(http.StatusBadRequest, "invalid name").With("field", "name")
+	d.Type = "https://example.com/validation"
+
+	b, err := json.Marshal(d)
+	equal(t, nil, err)
+	equal(t, `{"detail":"invalid name","field":"name","status":400,"title":"Bad Request","type":"https://example.com/validation"}`, string(b))
+
+	out := new(problem.Details)
+	err = json.Unmarshal(b, out)
+	equal(t, nil, err)
+	equal(t, d, out)
+}
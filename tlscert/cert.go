@@ -1,10 +1,17 @@
 package tlscert
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
 	"embed"
 	"errors"
+	"math/big"
 	"time"
 )
 
@@ -16,8 +23,24 @@ var (
 	KeyPEMBlockIsEmpty  = errors.New("PEM key block is empty")
 	NoValid             = errors.New("no valid certificate")
 	AppendCertFailed    = errors.New("failed to add CA's certificate")
+	TemplateIsEmpty     = errors.New("the certificate template is empty")
+	RootCAIsEmpty       = errors.New("the root CA loader returned no certificate")
+	RootCAKeyInvalid    = errors.New("the root CA private key cannot be used for signing")
+	UnknownKeyAlgorithm = errors.New("unknown key algorithm")
 )
 
+// KeyAlgorithm is the algorithm of the private key generated by Loader.LoadGenerated.
+type KeyAlgorithm int
+
+const (
+	RSA KeyAlgorithm = iota
+	ECDSAP256
+	ECDSAP384
+	Ed25519
+)
+
+const defaultBits = 2048
+
 type CertificatesLoader func() ([]tls.Certificate, *x509.CertPool, error)
 
 func ServerTLSConfig(loader CertificatesLoader) (*tls.Config, error) {
@@ -49,9 +72,10 @@ type Loader struct {
 	CertFilePath, KeyFilePath string
 	CertPEMBlock, KeyPEMBlock []byte
 
-	Template    *x509.Certificate
-	LoadRootCAs CertificatesLoader
-	Bits        int
+	Template     *x509.Certificate
+	LoadRootCAs  CertificatesLoader
+	Bits         int
+	KeyAlgorithm KeyAlgorithm
 }
 
 func certValidate(certificate *tls.Certificate) error {
@@ -155,3 +179,111 @@ func (l *Loader) LoadFromBytes() ([]tls.Certificate, *x509.CertPool, error) {
 
 	return []tls.Certificate{certificate}, nil, nil
 }
+
+// LoadGenerated generates a new private key and a certificate based on the Template.
+// The key is created according to the KeyAlgorithm, for RSA keys Bits is used as the key size (2048 by default).
+// If LoadRootCAs is specified, the certificate is signed by the first certificate it returns,
+// otherwise the certificate is self-signed.
+// The returned *x509.CertPool contains the certificate that signed the generated one.
+// To use this function you must specify Template in the Loader.
+func (l *Loader) LoadGenerated() ([]tls.Certificate, *x509.CertPool, error) {
+	if l.Template == nil {
+		return nil, nil, TemplateIsEmpty
+	}
+
+	key, err := l.generateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := *l.Template
+	if template.SerialNumber == nil {
+		if template.SerialNumber, err = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	parent, signer := &template, crypto.Signer(key)
+	if l.LoadRootCAs != nil {
+		if parent, signer, err = l.loadRootCA(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var der []byte
+	if der, err = x509.CreateCertificate(rand.Reader, &template, parent, key.Public(), signer); err != nil {
+		return nil, nil, err
+	}
+
+	var leaf *x509.Certificate
+	if leaf, err = x509.ParseCertificate(der); err != nil {
+		return nil, nil, err
+	}
+
+	certificate := tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	if parent != &template {
+		certificate.Certificate = append(certificate.Certificate, parent.Raw)
+	} else {
+		parent = leaf
+	}
+
+	if err = certValidate(&certificate); err != nil {
+		return nil, nil, err
+	}
+
+	certPool := x509.NewCertPool()
+	certPool.AddCert(parent)
+
+	return []tls.Certificate{certificate}, certPool, nil
+}
+
+func (l *Loader) generateKey() (crypto.Signer, error) {
+	switch l.KeyAlgorithm {
+	case RSA:
+		bits := l.Bits
+		if bits == 0 {
+			bits = defaultBits
+		}
+		return rsa.GenerateKey(rand.Reader, bits)
+	case ECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case ECDSAP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case Ed25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, UnknownKeyAlgorithm
+	}
+}
+
+func (l *Loader) loadRootCA() (*x509.Certificate, crypto.Signer, error) {
+	certificates, _, err := l.LoadRootCAs()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(certificates) == 0 || len(certificates[0].Certificate) == 0 {
+		return nil, nil, RootCAIsEmpty
+	}
+
+	ca := certificates[0]
+
+	signer, ok := ca.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, RootCAKeyInvalid
+	}
+
+	leaf := ca.Leaf
+	if leaf == nil {
+		if leaf, err = x509.ParseCertificate(ca.Certificate[0]); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return leaf, signer, nil
+}
@@ -0,0 +1,222 @@
+package tlscert_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/easy-techno-lab/proton/tlscert"
+)
+
+func equal(t *testing.T, exp, got any) {
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("Not equal:\nexp: %v\ngot: %v", exp, got)
+	}
+}
+
+func caTemplate() *x509.Certificate {
+	return &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "proton test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+}
+
+func leafTemplate() *x509.Certificate {
+	return &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().Add(time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+}
+
+func TestLoader_LoadGenerated(t *testing.T) {
+	ca := &tlscert.Loader{Template: caTemplate(), KeyAlgorithm: tlscert.ECDSAP256}
+
+	var tests = []struct {
+		name   string
+		loader *tlscert.Loader
+		chain  int
+		err    error
+	}{
+		{
+			name:   "self-signed RSA",
+			loader: &tlscert.Loader{Template: leafTemplate(), Bits: 1024},
+			chain:  1,
+		},
+		{
+			name:   "self-signed ECDSA",
+			loader: &tlscert.Loader{Template: leafTemplate(), KeyAlgorithm: tlscert.ECDSAP384},
+			chain:  1,
+		},
+		{
+			name:   "CA-issued Ed25519",
+			loader: &tlscert.Loader{Template: leafTemplate(), KeyAlgorithm: tlscert.Ed25519, LoadRootCAs: ca.LoadGenerated},
+			chain:  2,
+		},
+		{
+			name:   "empty template",
+			loader: &tlscert.Loader{},
+			err:    tlscert.TemplateIsEmpty,
+		},
+		{
+			name:   "unknown key algorithm",
+			loader: &tlscert.Loader{Template: leafTemplate(), KeyAlgorithm: -1},
+			err:    tlscert.UnknownKeyAlgorithm,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			certificates, certPool, err := test.loader.LoadGenerated()
+			if test.err != nil {
+				equal(t, true, errors.Is(err, test.err))
+				return
+			}
+			equal(t, nil, err)
+			equal(t, 1, len(certificates))
+			equal(t, test.chain, len(certificates[0].Certificate))
+
+			intermediates := x509.NewCertPool()
+			for _, raw := range certificates[0].Certificate[1:] {
+				cert, err := x509.ParseCertificate(raw)
+				equal(t, nil, err)
+				intermediates.AddCert(cert)
+			}
+
+			_, err = certificates[0].Leaf.Verify(x509.VerifyOptions{
+				DNSName:       "localhost",
+				Roots:         certPool,
+				Intermediates: intermediates,
+			})
+			equal(t, nil, err)
+		})
+	}
+}
+
+func TestManager_Reload(t *testing.T) {
+	valid := &tlscert.Loader{Template: leafTemplate(), KeyAlgorithm: tlscert.ECDSAP256}
+
+	var fail bool
+	manager := &tlscert.Manager{
+		Loader: func() ([]tls.Certificate, *x509.CertPool, error) {
+			if fail {
+				return nil, nil, tlscert.NoValid
+			}
+			return valid.LoadGenerated()
+		},
+	}
+
+	_, err := manager.GetCertificate(&tls.ClientHelloInfo{})
+	equal(t, tlscert.NotLoaded, err)
+
+	equal(t, nil, manager.Reload())
+
+	first, err := manager.GetCertificate(&tls.ClientHelloInfo{})
+	equal(t, nil, err)
+
+	equal(t, nil, manager.Reload())
+
+	second, err := manager.GetCertificate(&tls.ClientHelloInfo{})
+	equal(t, nil, err)
+	equal(t, false, first.Leaf.SerialNumber.Cmp(second.Leaf.SerialNumber) == 0)
+
+	fail = true
+	equal(t, tlscert.NoValid, manager.Reload())
+
+	third, err := manager.GetCertificate(&tls.ClientHelloInfo{})
+	equal(t, nil, err)
+	equal(t, second, third)
+}
+
+// writeGenerated writes a new generated certificate and its key to the files and returns its serial number.
+func writeGenerated(t *testing.T, certFile, keyFile string, modTime time.Time) string {
+	certificates, _, err := (&tlscert.Loader{Template: leafTemplate(), KeyAlgorithm: tlscert.ECDSAP256}).LoadGenerated()
+	equal(t, nil, err)
+
+	key, err := x509.MarshalPKCS8PrivateKey(certificates[0].PrivateKey)
+	equal(t, nil, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificates[0].Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key})
+
+	equal(t, nil, os.WriteFile(certFile, certPEM, 0o600))
+	equal(t, nil, os.WriteFile(keyFile, keyPEM, 0o600))
+	equal(t, nil, os.Chtimes(certFile, modTime, modTime))
+	equal(t, nil, os.Chtimes(keyFile, modTime, modTime))
+
+	return certificates[0].Leaf.SerialNumber.String()
+}
+
+func TestManager_Watch(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	serial := writeGenerated(t, certFile, keyFile, time.Now().Add(-time.Minute))
+
+	// failing simulates files that are not completely written yet
+	var failing atomic.Bool
+	loader := &tlscert.Loader{CertFilePath: certFile, KeyFilePath: keyFile}
+
+	manager := &tlscert.Manager{
+		Loader: func() ([]tls.Certificate, *x509.CertPool, error) {
+			if failing.Load() {
+				return nil, nil, errors.New("the key is not written yet")
+			}
+			return loader.LoadFromFiles()
+		},
+		Files:    []string{certFile, keyFile},
+		Interval: 10 * time.Millisecond,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the certificates are loaded by Watch without Reload
+	go manager.Watch(ctx)
+
+	waitSerial := func(serial string) {
+		for i := 0; ; i++ {
+			if cert, err := manager.GetCertificate(&tls.ClientHelloInfo{}); err == nil {
+				leaf, err := x509.ParseCertificate(cert.Certificate[0])
+				equal(t, nil, err)
+				if leaf.SerialNumber.String() == serial {
+					return
+				}
+			}
+			if i == 100 {
+				t.Fatal("the certificate is not loaded")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	waitSerial(serial)
+
+	waitSerial(writeGenerated(t, certFile, keyFile, time.Now()))
+
+	// a failed reload is retried although the files do not change again
+	failing.Store(true)
+	serial = writeGenerated(t, certFile, keyFile, time.Now().Add(time.Minute))
+	time.Sleep(50 * time.Millisecond)
+	failing.Store(false)
+
+	waitSerial(serial)
+}
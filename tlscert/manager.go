@@ -0,0 +1,220 @@
+package tlscert
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	LoaderIsEmpty = errors.New("the certificates loader is empty")
+	NotLoaded     = errors.New("certificates are not loaded")
+)
+
+const defaultInterval = time.Minute
+
+// Manager keeps the certificates returned by the Loader up to date without restarting the server.
+// Use GetCertificate and GetClientCertificate as callbacks in *tls.Config.
+//
+//	Loader — CertificatesLoader that is re-run on every reload.
+//	Files — files to watch, when one of them changes the certificates are reloaded.
+//	Interval — how often Files are checked, if Files is empty the certificates are reloaded every Interval.
+//
+// If the new certificates cannot be loaded or are not valid, the Manager keeps the previous ones.
+type Manager struct {
+	Loader   CertificatesLoader
+	Files    []string
+	Interval time.Duration
+
+	mu           sync.RWMutex
+	certificates []tls.Certificate
+	certPool     *x509.CertPool
+
+	watchMu  sync.Mutex
+	modTimes map[string]time.Time
+}
+
+// Reload runs the Loader and replaces the current certificates if the new ones are valid.
+func (m *Manager) Reload() error {
+	if m.Loader == nil {
+		return LoaderIsEmpty
+	}
+
+	certificates, certPool, err := m.Loader()
+	if err != nil {
+		return err
+	}
+
+	if len(certificates) == 0 {
+		return NoValid
+	}
+
+	for i := range certificates {
+		if err = certValidate(&certificates[i]); err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	m.certificates, m.certPool = certificates, certPool
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Watch loads the certificates, then reloads them when the Files change or every Interval until ctx is done.
+// Errors are logged and the previous certificates are kept.
+func (m *Manager) Watch(ctx context.Context) {
+	interval := m.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	modTimes, _ := m.modified()
+	if err := m.Reload(); err != nil {
+		slog.ErrorContext(ctx, "load certificates", "error", err)
+	} else {
+		m.setModTimes(modTimes)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			modTimes, changed := m.modified()
+			if len(m.Files) != 0 && !changed {
+				continue
+			}
+			if err := m.Reload(); err != nil {
+				// the modification times are not stored, so that the reload is retried on the next tick
+				slog.ErrorContext(ctx, "reload certificates", "error", err)
+				continue
+			}
+			m.setModTimes(modTimes)
+			slog.InfoContext(ctx, "certificates reloaded")
+		}
+	}
+}
+
+// modified returns the modification times of the Files and reports whether any of them has changed
+// since the certificates were last loaded.
+func (m *Manager) modified() (map[string]time.Time, bool) {
+	m.watchMu.Lock()
+	defer m.watchMu.Unlock()
+
+	modTimes := make(map[string]time.Time, len(m.Files))
+
+	var changed bool
+	for _, file := range m.Files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		modTimes[file] = info.ModTime()
+		if !info.ModTime().Equal(m.modTimes[file]) {
+			changed = true
+		}
+	}
+
+	return modTimes, changed
+}
+
+// setModTimes stores the modification times of the Files the certificates have been loaded from.
+func (m *Manager) setModTimes(modTimes map[string]time.Time) {
+	m.watchMu.Lock()
+	defer m.watchMu.Unlock()
+
+	m.modTimes = modTimes
+}
+
+// Certificates returns the current certificates and *x509.CertPool.
+// It can be used as a CertificatesLoader.
+func (m *Manager) Certificates() ([]tls.Certificate, *x509.CertPool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.certificates) == 0 {
+		return nil, nil, NotLoaded
+	}
+
+	return m.certificates, m.certPool, nil
+}
+
+// GetCertificate returns the current certificate supported by the client.
+// Use as tls.Config.GetCertificate.
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.certificates) == 0 {
+		return nil, NotLoaded
+	}
+
+	for i := range m.certificates {
+		if hello.SupportsCertificate(&m.certificates[i]) == nil {
+			return &m.certificates[i], nil
+		}
+	}
+
+	return &m.certificates[0], nil
+}
+
+// GetClientCertificate returns the current certificate supported by the server.
+// Use as tls.Config.GetClientCertificate.
+func (m *Manager) GetClientCertificate(request *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.certificates) == 0 {
+		return nil, NotLoaded
+	}
+
+	for i := range m.certificates {
+		if request.SupportsCertificate(&m.certificates[i]) == nil {
+			return &m.certificates[i], nil
+		}
+	}
+
+	return &m.certificates[0], nil
+}
+
+// ServerTLSConfig returns *tls.Config which serves the current certificates and client CAs.
+func (m *Manager) ServerTLSConfig() *tls.Config {
+	config := &tls.Config{GetCertificate: m.GetCertificate}
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		m.mu.RLock()
+		certPool := m.certPool
+		m.mu.RUnlock()
+
+		if certPool == nil {
+			return nil, nil
+		}
+
+		c := config.Clone()
+		c.GetConfigForClient = nil
+		c.ClientCAs = certPool
+		return c, nil
+	}
+	return config
+}
+
+// ClientTLSConfig returns *tls.Config which presents the current certificates to the server.
+// RootCAs are taken from the certificates loaded at the time of the call.
+func (m *Manager) ClientTLSConfig() *tls.Config {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return &tls.Config{
+		GetClientCertificate: m.GetClientCertificate,
+		RootCAs:              m.certPool,
+	}
+}
@@ -0,0 +1,199 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/easy-techno-lab/proton/utils/log"
+)
+
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+
+	FlagSampled byte = 0x01
+
+	version = "00"
+)
+
+var InvalidTraceparent = errors.New("invalid traceparent")
+
+type contextKey int
+
+const (
+	spanContextCtxKey contextKey = iota + 1
+	spanCtxKey
+)
+
+// TraceID is a W3C Trace Context trace ID.
+type TraceID [16]byte
+
+// NewTraceID returns a new random TraceID.
+func NewTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+// IsValid reports whether the TraceID is not all zeros.
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID is a W3C Trace Context span (parent) ID.
+type SpanID [8]byte
+
+// NewSpanID returns a new random SpanID.
+func NewSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+// IsValid reports whether the SpanID is not all zeros.
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext identifies a span within a trace.
+//
+//	TraceID — ID of the whole trace.
+//	SpanID — ID of the span.
+//	Flags — trace flags, FlagSampled is the only defined flag.
+//	State — vendor-specific data of the tracestate header, it is propagated as is.
+//	Remote — true if the SpanContext was received from another service.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Flags   byte
+	State   string
+	Remote  bool
+}
+
+// NewSpanContext returns a new sampled SpanContext which starts a new trace.
+func NewSpanContext() SpanContext {
+	return SpanContext{TraceID: NewTraceID(), SpanID: NewSpanID(), Flags: FlagSampled}
+}
+
+// IsValid reports whether both TraceID and SpanID are valid.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Sampled reports whether the FlagSampled is set.
+func (sc SpanContext) Sampled() bool {
+	return sc.Flags&FlagSampled != 0
+}
+
+// Child returns a SpanContext of a child span: the same trace with a new SpanID.
+func (sc SpanContext) Child() SpanContext {
+	return SpanContext{TraceID: sc.TraceID, SpanID: NewSpanID(), Flags: sc.Flags, State: sc.State}
+}
+
+// Traceparent returns the value of the traceparent header.
+func (sc SpanContext) Traceparent() string {
+	b := make([]byte, 0, 55)
+	b = append(b, version...)
+	b = append(b, '-')
+	b = hex.AppendEncode(b, sc.TraceID[:])
+	b = append(b, '-')
+	b = hex.AppendEncode(b, sc.SpanID[:])
+	b = append(b, '-')
+	b = hex.AppendEncode(b, []byte{sc.Flags})
+	return string(b)
+}
+
+// ParseTraceparent parses the value of the traceparent header.
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return sc, InvalidTraceparent
+	}
+
+	// future versions may append fields, version 00 must have exactly four
+	if parts[0] == version && len(parts) != 4 {
+		return sc, InvalidTraceparent
+	}
+
+	for _, part := range parts[:4] {
+		if strings.ToLower(part) != part {
+			return sc, InvalidTraceparent
+		}
+	}
+
+	var flags [1]byte
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, InvalidTraceparent
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, InvalidTraceparent
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, InvalidTraceparent
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return sc, InvalidTraceparent
+	}
+	sc.Flags = flags[0]
+
+	if !sc.IsValid() {
+		return sc, InvalidTraceparent
+	}
+
+	return sc, nil
+}
+
+// Extract returns the remote SpanContext from the traceparent and tracestate headers.
+func Extract(h http.Header) (SpanContext, bool) {
+	sc, err := ParseTraceparent(h.Get(TraceparentHeader))
+	if err != nil {
+		return SpanContext{}, false
+	}
+
+	sc.State = strings.Join(h.Values(TracestateHeader), ",")
+	sc.Remote = true
+
+	return sc, true
+}
+
+// Inject sets the traceparent and tracestate headers from the SpanContext.
+func Inject(sc SpanContext, h http.Header) {
+	h.Set(TraceparentHeader, sc.Traceparent())
+	if sc.State != "" {
+		h.Set(TracestateHeader, sc.State)
+	} else {
+		h.Del(TracestateHeader)
+	}
+}
+
+// ContextWithSpanContext returns a copy of ctx with the SpanContext.
+// The trace and span IDs are also stored under log.TraceCtxKey and log.SpanCtxKey for log.TraceHandler.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	ctx = context.WithValue(ctx, spanContextCtxKey, sc)
+	ctx = context.WithValue(ctx, log.TraceCtxKey, sc.TraceID.String())
+	return context.WithValue(ctx, log.SpanCtxKey, sc.SpanID.String())
+}
+
+// SpanContextFromContext returns the SpanContext stored in ctx.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextCtxKey).(SpanContext)
+	return sc, ok
+}
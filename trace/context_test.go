@@ -0,0 +1,102 @@
+package trace_test
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/easy-techno-lab/proton/trace"
+	"github.com/easy-techno-lab/proton/utils/log"
+)
+
+func equal(t *testing.T, exp, got any) {
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("Not equal:\nexp: %v\ngot: %v", exp, got)
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	var tests = []struct {
+		name    string
+		input   string
+		traceID string
+		spanID  string
+		sampled bool
+		err     error
+	}{
+		{
+			name:    "valid sampled",
+			input:   "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			traceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			spanID:  "00f067aa0ba902b7",
+			sampled: true,
+		},
+		{
+			name:    "future version with extra fields",
+			input:   "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra",
+			traceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			spanID:  "00f067aa0ba902b7",
+		},
+		{
+			name:  "version 00 with extra fields",
+			input: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			err:   trace.InvalidTraceparent,
+		},
+		{
+			name:  "zero trace ID",
+			input: "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			err:   trace.InvalidTraceparent,
+		},
+		{
+			name:  "upper case",
+			input: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+			err:   trace.InvalidTraceparent,
+		},
+		{
+			name:  "invalid version",
+			input: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			err:   trace.InvalidTraceparent,
+		},
+		{
+			name:  "empty",
+			input: "",
+			err:   trace.InvalidTraceparent,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sc, err := trace.ParseTraceparent(test.input)
+			equal(t, test.err, err)
+			if test.err != nil {
+				return
+			}
+			equal(t, test.traceID, sc.TraceID.String())
+			equal(t, test.spanID, sc.SpanID.String())
+			equal(t, test.sampled, sc.Sampled())
+		})
+	}
+}
+
+func TestInjectExtract(t *testing.T) {
+	sc := trace.NewSpanContext()
+	sc.State = "vendor=value"
+
+	h := make(http.Header)
+	trace.Inject(sc, h)
+
+	got, ok := trace.Extract(h)
+	equal(t, true, ok)
+
+	sc.Remote = true
+	equal(t, sc, got)
+
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	fromCtx, ok := trace.SpanContextFromContext(ctx)
+	equal(t, true, ok)
+	equal(t, sc, fromCtx)
+	equal(t, sc.TraceID.String(), ctx.Value(log.TraceCtxKey))
+	equal(t, sc.SpanID.String(), ctx.Value(log.SpanCtxKey))
+}
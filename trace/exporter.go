@@ -0,0 +1,258 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// SpanExporter receives ended spans.
+type SpanExporter interface {
+	ExportSpans(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// InMemoryExporter keeps exported spans in memory, it is intended for tests.
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+// ExportSpans stores the spans.
+func (e *InMemoryExporter) ExportSpans(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, spans...)
+
+	return nil
+}
+
+// Shutdown does nothing.
+func (e *InMemoryExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// Spans returns a copy of the stored spans.
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]SpanData(nil), e.spans...)
+}
+
+// Reset removes the stored spans.
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = nil
+}
+
+// FileExporter writes spans as JSON lines.
+type FileExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewFileExporter returns a new FileExporter which writes to w.
+// If w is an io.Closer, it is closed on Shutdown.
+func NewFileExporter(w io.Writer) *FileExporter {
+	return &FileExporter{w: w}
+}
+
+// OpenFileExporter returns a new FileExporter which appends to the file, creating it if necessary.
+func OpenFileExporter(name string) (*FileExporter, error) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return NewFileExporter(f), nil
+}
+
+// ExportSpans writes every span as a line of JSON.
+func (e *FileExporter) ExportSpans(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	enc := json.NewEncoder(e.w)
+	for _, span := range spans {
+		if err := enc.Encode(span); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Shutdown closes the underlying writer if it is an io.Closer.
+func (e *FileExporter) Shutdown(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if c, ok := e.w.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+const (
+	defaultBatchSize     = 512
+	defaultQueueSize     = 2048
+	defaultFlushInterval = 5 * time.Second
+)
+
+var BatcherIsShutdown = errors.New("the batcher is shut down")
+
+// BatchOptions represents the configuration of the Batcher.
+// Zero values are replaced with defaults.
+type BatchOptions struct {
+	BatchSize     int           // Maximum number of spans in one export (512 by default).
+	QueueSize     int           // Maximum number of queued spans, extra spans are dropped (2048 by default).
+	FlushInterval time.Duration // Maximum time a span waits in the queue (5s by default).
+}
+
+// Batcher is a SpanExporter which queues spans and exports them in batches in the background,
+// so that ending a span does not wait for a slow exporter.
+type Batcher struct {
+	exporter SpanExporter
+	opts     BatchOptions
+
+	queue   chan SpanData
+	flush   chan chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+
+	once sync.Once
+	err  error
+}
+
+// NewBatcher returns a new Batcher which exports spans with the exporter.
+func NewBatcher(exporter SpanExporter, opts *BatchOptions) *Batcher {
+	o := BatchOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = defaultBatchSize
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = defaultQueueSize
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = defaultFlushInterval
+	}
+
+	b := &Batcher{
+		exporter: exporter,
+		opts:     o,
+		queue:    make(chan SpanData, o.QueueSize),
+		flush:    make(chan chan struct{}),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	go b.run()
+
+	return b
+}
+
+// ExportSpans queues the spans, spans that do not fit into the queue are dropped.
+func (b *Batcher) ExportSpans(_ context.Context, spans []SpanData) error {
+	select {
+	case <-b.done:
+		return BatcherIsShutdown
+	default:
+	}
+
+	for _, span := range spans {
+		select {
+		case b.queue <- span:
+		default:
+			slog.Warn("span queue is full, span dropped", "name", span.Name)
+		}
+	}
+
+	return nil
+}
+
+// ForceFlush exports all queued spans.
+func (b *Batcher) ForceFlush(ctx context.Context) error {
+	flushed := make(chan struct{})
+
+	select {
+	case b.flush <- flushed:
+	case <-b.done:
+		return BatcherIsShutdown
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown exports all queued spans and shuts down the exporter.
+// It is done once, the following calls wait for it and return its error.
+func (b *Batcher) Shutdown(ctx context.Context) error {
+	b.once.Do(func() {
+		err := b.ForceFlush(ctx)
+
+		close(b.done)
+		<-b.stopped
+
+		b.err = errors.Join(err, b.exporter.Shutdown(ctx))
+	})
+
+	return b.err
+}
+
+func (b *Batcher) run() {
+	defer close(b.stopped)
+
+	ticker := time.NewTicker(b.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, b.opts.BatchSize)
+
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := b.exporter.ExportSpans(context.Background(), batch); err != nil {
+			slog.Error("export spans", "error", err)
+		}
+		batch = make([]SpanData, 0, b.opts.BatchSize)
+	}
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case span := <-b.queue:
+			if batch = append(batch, span); len(batch) >= b.opts.BatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case flushed := <-b.flush:
+			for n := len(b.queue); n > 0; n-- {
+				if batch = append(batch, <-b.queue); len(batch) >= b.opts.BatchSize {
+					export()
+				}
+			}
+			export()
+			close(flushed)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+const defaultOTLPEndpoint = "http://localhost:4318/v1/traces"
+
+// OTLPExporter sends spans to an OpenTelemetry collector using OTLP/HTTP with the JSON encoding.
+// Wrap it with NewBatcher, so that requests to the collector do not slow down ending spans.
+//
+//	Endpoint — URL of the traces endpoint ("http://localhost:4318/v1/traces" by default).
+//	ServiceName — value of the service.name resource attribute.
+//	Header — additional request headers, for example authorization.
+//	Client — *http.Client used to send requests (http.DefaultClient by default).
+type OTLPExporter struct {
+	Endpoint    string
+	ServiceName string
+	Header      http.Header
+	Client      *http.Client
+}
+
+// ExportSpans sends the spans to the collector.
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+
+	endpoint := e.Endpoint
+	if endpoint == "" {
+		endpoint = defaultOTLPEndpoint
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	for key, values := range e.Header {
+		request.Header[key] = values
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = response.Body.Close() }()
+
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		b, _ := io.ReadAll(io.LimitReader(response.Body, 1<<10))
+		return fmt.Errorf("OTLP export: %s: %s", response.Status, bytes.TrimSpace(b))
+	}
+
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	return nil
+}
+
+// Shutdown does nothing, the exporter has no resources to release.
+func (e *OTLPExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// The OTLP/JSON message types, only the fields used by the exporter are declared.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	TraceState        string         `json:"traceState,omitempty"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Flags             uint32         `json:"flags"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Message string `json:"message,omitempty"`
+	Code    int    `json:"code"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func (e *OTLPExporter) request(spans []SpanData) *otlpRequest {
+	serviceName := e.ServiceName
+	if serviceName == "" {
+		serviceName = "unknown_service"
+	}
+
+	otlpSpans := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		s := otlpSpan{
+			TraceID:           span.SpanContext.TraceID.String(),
+			SpanID:            span.SpanContext.SpanID.String(),
+			TraceState:        span.SpanContext.State,
+			Flags:             uint32(span.SpanContext.Flags),
+			Name:              span.Name,
+			Kind:              otlpKind(span.Kind),
+			StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+			Status:            otlpStatus{Message: span.StatusDescription, Code: int(span.Status)},
+		}
+		if span.Parent.IsValid() {
+			s.ParentSpanID = span.Parent.String()
+		}
+		otlpSpans = append(otlpSpans, s)
+	}
+
+	return &otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: otlpAttributes([]slog.Attr{slog.String("service.name", serviceName)}),
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/easy-techno-lab/proton/trace"},
+				Spans: otlpSpans,
+			}},
+		}},
+	}
+}
+
+// otlpKind converts the SpanKind into the OTLP span kind: 1 — internal, 2 — server, 3 — client.
+func otlpKind(kind SpanKind) int {
+	switch kind {
+	case KindServer:
+		return 2
+	case KindClient:
+		return 3
+	default:
+		return 1
+	}
+}
+
+func otlpAttributes(attrs []slog.Attr) []otlpKeyValue {
+	kvs := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		var v otlpValue
+		switch value := attr.Value.Resolve(); value.Kind() {
+		case slog.KindBool:
+			b := value.Bool()
+			v.BoolValue = &b
+		case slog.KindInt64:
+			s := strconv.FormatInt(value.Int64(), 10)
+			v.IntValue = &s
+		case slog.KindUint64:
+			s := strconv.FormatUint(value.Uint64(), 10)
+			v.IntValue = &s
+		case slog.KindFloat64:
+			f := value.Float64()
+			v.DoubleValue = &f
+		default:
+			s := value.String()
+			v.StringValue = &s
+		}
+		kvs = append(kvs, otlpKeyValue{Key: attr.Key, Value: v})
+	}
+	return kvs
+}
@@ -0,0 +1,270 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SpanKind describes the relationship of the span to its parent and children.
+type SpanKind int
+
+const (
+	KindInternal SpanKind = iota
+	KindServer
+	KindClient
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case KindInternal:
+		return "internal"
+	case KindServer:
+		return "server"
+	case KindClient:
+		return "client"
+	default:
+		return fmt.Sprintf("SpanKind(%d)", int(k))
+	}
+}
+
+// StatusCode is the status of the span.
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = iota
+	StatusOK
+	StatusError
+)
+
+func (c StatusCode) String() string {
+	switch c {
+	case StatusUnset:
+		return "unset"
+	case StatusOK:
+		return "ok"
+	case StatusError:
+		return "error"
+	default:
+		return fmt.Sprintf("StatusCode(%d)", int(c))
+	}
+}
+
+var exporter atomic.Pointer[SpanExporter]
+
+// SetExporter sets the SpanExporter which receives all sampled spans when they end.
+// If the exporter is nil, spans are not exported.
+func SetExporter(e SpanExporter) {
+	if e == nil {
+		exporter.Store(nil)
+		return
+	}
+	exporter.Store(&e)
+}
+
+// StartOption configures a span started by Start.
+type StartOption func(*Span)
+
+// WithKind sets the kind of the span.
+func WithKind(kind SpanKind) StartOption {
+	return func(s *Span) {
+		s.data.Kind = kind
+	}
+}
+
+// WithAttributes sets the initial attributes of the span.
+func WithAttributes(attrs ...slog.Attr) StartOption {
+	return func(s *Span) {
+		s.data.Attributes = append(s.data.Attributes, attrs...)
+	}
+}
+
+// Start starts a new span which is a child of the span context stored in ctx, or the root of a new trace.
+// The returned context contains the span and its SpanContext.
+// The span must be ended with End.
+func Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	s := &Span{data: SpanData{Name: name, StartTime: time.Now()}}
+
+	if parent, ok := SpanContextFromContext(ctx); ok && parent.IsValid() {
+		s.data.SpanContext = parent.Child()
+		s.data.Parent = parent.SpanID
+	} else {
+		s.data.SpanContext = NewSpanContext()
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	ctx = ContextWithSpanContext(ctx, s.data.SpanContext)
+
+	return context.WithValue(ctx, spanCtxKey, s), s
+}
+
+// SpanFromContext returns the span stored in ctx by Start or nil.
+// All methods of a nil *Span are no-ops.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanCtxKey).(*Span)
+	return s
+}
+
+// Span is an operation within a trace.
+type Span struct {
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// SpanContext returns the SpanContext of the span.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext
+}
+
+// SetName changes the name of the span.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.ended {
+		s.data.Name = name
+	}
+}
+
+// SetAttributes adds the attributes to the span.
+func (s *Span) SetAttributes(attrs ...slog.Attr) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.ended {
+		s.data.Attributes = append(s.data.Attributes, attrs...)
+	}
+}
+
+// SetStatus sets the status of the span, the description is used only with StatusError.
+func (s *Span) SetStatus(code StatusCode, description string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return
+	}
+
+	s.data.Status = code
+	if code == StatusError {
+		s.data.StatusDescription = description
+	} else {
+		s.data.StatusDescription = ""
+	}
+}
+
+// RecordError sets the error status of the span and adds the error message as an attribute.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.SetAttributes(slog.String("exception.message", err.Error()))
+	s.SetStatus(StatusError, err.Error())
+}
+
+// End ends the span and sends it to the SpanExporter if the span is sampled.
+// Calls after the first one are ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTime = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if !data.SpanContext.Sampled() {
+		return
+	}
+
+	e := exporter.Load()
+	if e == nil {
+		return
+	}
+
+	if err := (*e).ExportSpans(context.Background(), []SpanData{data}); err != nil {
+		slog.Error("export spans", "error", err)
+	}
+}
+
+// SpanData is the snapshot of an ended span passed to the SpanExporter.
+type SpanData struct {
+	Name              string
+	Kind              SpanKind
+	SpanContext       SpanContext
+	Parent            SpanID // zero for the root span
+	StartTime         time.Time
+	EndTime           time.Time
+	Attributes        []slog.Attr
+	Status            StatusCode
+	StatusDescription string
+}
+
+// MarshalJSON encodes the SpanData as a flat JSON object.
+func (d SpanData) MarshalJSON() ([]byte, error) {
+	attrs := make(map[string]any, len(d.Attributes))
+	for _, attr := range d.Attributes {
+		attrs[attr.Key] = attr.Value.Resolve().Any()
+	}
+
+	v := struct {
+		TraceID           string         `json:"trace_id"`
+		SpanID            string         `json:"span_id"`
+		ParentSpanID      string         `json:"parent_span_id,omitempty"`
+		Name              string         `json:"name"`
+		Kind              string         `json:"kind"`
+		StartTime         time.Time      `json:"start_time"`
+		EndTime           time.Time      `json:"end_time"`
+		Duration          string         `json:"duration"`
+		Attributes        map[string]any `json:"attributes,omitempty"`
+		Status            string         `json:"status"`
+		StatusDescription string         `json:"status_description,omitempty"`
+	}{
+		TraceID:           d.SpanContext.TraceID.String(),
+		SpanID:            d.SpanContext.SpanID.String(),
+		Name:              d.Name,
+		Kind:              d.Kind.String(),
+		StartTime:         d.StartTime,
+		EndTime:           d.EndTime,
+		Duration:          d.EndTime.Sub(d.StartTime).String(),
+		Attributes:        attrs,
+		Status:            d.Status.String(),
+		StatusDescription: d.StatusDescription,
+	}
+
+	if d.Parent.IsValid() {
+		v.ParentSpanID = d.Parent.String()
+	}
+
+	return json.Marshal(v)
+}
@@ -0,0 +1,219 @@
+package trace_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/easy-techno-lab/proton/trace"
+)
+
+func TestStart(t *testing.T) {
+	exp := new(trace.InMemoryExporter)
+	trace.SetExporter(exp)
+	defer trace.SetExporter(nil)
+
+	ctx, root := trace.Start(context.Background(), "root", trace.WithKind(trace.KindServer))
+	equal(t, root, trace.SpanFromContext(ctx))
+
+	_, child := trace.Start(ctx, "child", trace.WithAttributes(slog.String("key", "value")))
+	child.RecordError(errors.New("failed"))
+	child.End()
+	child.SetName("ignored")
+	child.End()
+
+	root.SetStatus(trace.StatusOK, "ignored")
+	root.End()
+
+	spans := exp.Spans()
+	equal(t, 2, len(spans))
+
+	c, r := spans[0], spans[1]
+	equal(t, "child", c.Name)
+	equal(t, trace.KindInternal, c.Kind)
+	equal(t, r.SpanContext.TraceID, c.SpanContext.TraceID)
+	equal(t, r.SpanContext.SpanID, c.Parent)
+	equal(t, trace.StatusError, c.Status)
+	equal(t, "failed", c.StatusDescription)
+	equal(t, []slog.Attr{slog.String("key", "value"), slog.String("exception.message", "failed")}, c.Attributes)
+
+	equal(t, "root", r.Name)
+	equal(t, trace.KindServer, r.Kind)
+	equal(t, false, r.Parent.IsValid())
+	equal(t, trace.StatusOK, r.Status)
+	equal(t, "", r.StatusDescription)
+	equal(t, false, r.EndTime.Before(r.StartTime))
+
+	// not sampled spans are not exported
+	exp.Reset()
+	sc := trace.NewSpanContext()
+	sc.Flags = 0
+	_, span := trace.Start(trace.ContextWithSpanContext(context.Background(), sc), "not sampled")
+	span.End()
+	equal(t, 0, len(exp.Spans()))
+
+	// methods of a nil span are no-ops
+	var nilSpan *trace.Span
+	nilSpan.SetAttributes(slog.Int("key", 1))
+	nilSpan.RecordError(errors.New("failed"))
+	nilSpan.End()
+	equal(t, (*trace.Span)(nil), trace.SpanFromContext(context.Background()))
+}
+
+func TestFileExporter(t *testing.T) {
+	buf := new(bytes.Buffer)
+	exp := trace.NewFileExporter(buf)
+
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	span := trace.SpanData{
+		Name:        "GET /users/{id}",
+		Kind:        trace.KindServer,
+		SpanContext: trace.SpanContext{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{2}, Flags: trace.FlagSampled},
+		Parent:      trace.SpanID{3},
+		StartTime:   start,
+		EndTime:     start.Add(time.Second),
+		Attributes:  []slog.Attr{slog.Int("http.response.status_code", 200)},
+		Status:      trace.StatusOK,
+	}
+
+	equal(t, nil, exp.ExportSpans(context.Background(), []trace.SpanData{span, span}))
+	equal(t, nil, exp.Shutdown(context.Background()))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	equal(t, 2, len(lines))
+
+	var got map[string]any
+	equal(t, nil, json.Unmarshal(lines[0], &got))
+	equal(t, map[string]any{
+		"trace_id":       "01000000000000000000000000000000",
+		"span_id":        "0200000000000000",
+		"parent_span_id": "0300000000000000",
+		"name":           "GET /users/{id}",
+		"kind":           "server",
+		"start_time":     "2024-01-02T03:04:05Z",
+		"end_time":       "2024-01-02T03:04:06Z",
+		"duration":       "1s",
+		"attributes":     map[string]any{"http.response.status_code": float64(200)},
+		"status":         "ok",
+	}, got)
+}
+
+func TestOTLPExporter(t *testing.T) {
+	received := make(chan []byte, 1)
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		equal(t, "/v1/traces", r.URL.Path)
+		equal(t, "application/json", r.Header.Get("Content-Type"))
+		equal(t, "secret", r.Header.Get("Authorization"))
+
+		b, _ := io.ReadAll(r.Body)
+		received <- b
+	}))
+	defer collector.Close()
+
+	exp := trace.NewBatcher(&trace.OTLPExporter{
+		Endpoint:    collector.URL + "/v1/traces",
+		ServiceName: "test",
+		Header:      http.Header{"Authorization": {"secret"}},
+	}, nil)
+
+	start := time.Unix(1, 5)
+	span := trace.SpanData{
+		Name:        "GET",
+		Kind:        trace.KindClient,
+		SpanContext: trace.SpanContext{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{2}, Flags: trace.FlagSampled},
+		StartTime:   start,
+		EndTime:     start.Add(time.Nanosecond),
+		Attributes: []slog.Attr{
+			slog.String("s", "v"),
+			slog.Int("i", 1),
+			slog.Bool("b", true),
+			slog.Float64("f", 0.5),
+		},
+		Status:            trace.StatusError,
+		StatusDescription: "failed",
+	}
+
+	equal(t, nil, exp.ExportSpans(context.Background(), []trace.SpanData{span}))
+	equal(t, nil, exp.Shutdown(context.Background()))
+
+	var got map[string]any
+	equal(t, nil, json.Unmarshal(<-received, &got))
+
+	str := func(s string) map[string]any { return map[string]any{"stringValue": s} }
+
+	equal(t, map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": []any{map[string]any{"key": "service.name", "value": str("test")}},
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "github.com/easy-techno-lab/proton/trace"},
+				"spans": []any{map[string]any{
+					"traceId":           "01000000000000000000000000000000",
+					"spanId":            "0200000000000000",
+					"flags":             float64(1),
+					"name":              "GET",
+					"kind":              float64(3),
+					"startTimeUnixNano": "1000000005",
+					"endTimeUnixNano":   "1000000006",
+					"attributes": []any{
+						map[string]any{"key": "s", "value": str("v")},
+						map[string]any{"key": "i", "value": map[string]any{"intValue": "1"}},
+						map[string]any{"key": "b", "value": map[string]any{"boolValue": true}},
+						map[string]any{"key": "f", "value": map[string]any{"doubleValue": 0.5}},
+					},
+					"status": map[string]any{"code": float64(2), "message": "failed"},
+				}},
+			}},
+		}},
+	}, got)
+
+	equal(t, trace.BatcherIsShutdown, exp.ExportSpans(context.Background(), []trace.SpanData{span}))
+}
+
+// countingExporter counts the calls of Shutdown.
+type countingExporter struct {
+	trace.InMemoryExporter
+	shutdowns atomic.Int32
+}
+
+func (e *countingExporter) Shutdown(context.Context) error {
+	e.shutdowns.Add(1)
+	return errors.New("closed")
+}
+
+func TestBatcher_Shutdown(t *testing.T) {
+	exp := new(countingExporter)
+	b := trace.NewBatcher(exp, nil)
+
+	span := trace.SpanData{Name: "GET"}
+	equal(t, nil, b.ExportSpans(context.Background(), []trace.SpanData{span}))
+
+	errs := make([]error, 5)
+
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = b.Shutdown(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	equal(t, int32(1), exp.shutdowns.Load())
+	equal(t, 1, len(exp.Spans()))
+	for _, err := range errs {
+		equal(t, "closed", err.Error())
+	}
+}
@@ -0,0 +1,421 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const redacted = "[REDACTED]"
+
+// DefaultRedactHeaders are the headers redacted by a Dumper with nil RedactHeaders.
+var DefaultRedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+// Dumper dumps HTTP requests and responses to the log.
+// Bodies are captured while they stream, so that dumping does not change how they are sent.
+// The zero value is ready to use.
+//
+//	MaxBody — maximum number of body bytes kept for the dump (16KiB by default), a negative value disables bodies.
+//	RedactHeaders — headers whose values are replaced with [REDACTED] (DefaultRedactHeaders if nil).
+//	RedactFields — JSON object keys and form fields whose values are replaced with [REDACTED], case-insensitive.
+//	Pretty — indent JSON bodies.
+type Dumper struct {
+	MaxBody       int
+	RedactHeaders []string
+	RedactFields  []string
+	Pretty        bool
+}
+
+var defaultDumper = new(Dumper)
+
+// Capture returns a new Capture which keeps up to MaxBody bytes.
+func (d *Dumper) Capture() *Capture {
+	limit := d.MaxBody
+	if limit == 0 {
+		limit = maxBody
+	}
+	return &Capture{max: max(limit, 0)}
+}
+
+// DumpRequest dumps the HTTP request with up to MaxBody bytes of its body and prints out.
+// The body is read up to the limit and restored, so that the request can still be sent or served.
+// Use Capture and Request to dump the body while it streams.
+func (d *Dumper) DumpRequest(ctx context.Context, r *http.Request, level slog.Level) {
+	c := d.Capture()
+	if r.Body != nil && r.Body != http.NoBody && c.max > 0 {
+		if err := c.prefetch(&r.Body); err != nil {
+			slog.ErrorContext(ctx, "HTTP REQUEST", "error", err)
+			return
+		}
+	}
+	d.Request(ctx, r, c, level)
+}
+
+// DumpResponse dumps the HTTP response with up to MaxBody bytes of its body and prints out.
+// The body is read up to the limit and restored.
+func (d *Dumper) DumpResponse(ctx context.Context, r *http.Response, level slog.Level) {
+	c := d.Capture()
+	if r.Body != nil && r.Body != http.NoBody && c.max > 0 {
+		if err := c.prefetch(&r.Body); err != nil {
+			slog.ErrorContext(ctx, "HTTP RESPONSE", "error", err)
+			return
+		}
+	}
+	d.Response(ctx, r, c, level)
+}
+
+// Request prints out the HTTP request with the body captured by c, c may be nil.
+func (d *Dumper) Request(ctx context.Context, r *http.Request, c *Capture, level slog.Level) {
+	b := new(bytes.Buffer)
+
+	uri := r.RequestURI
+	if uri == "" {
+		uri = r.URL.RequestURI()
+	}
+	proto := r.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	host := r.Host
+	if host == "" && r.URL != nil {
+		host = r.URL.Host
+	}
+
+	_, _ = fmt.Fprintf(b, "%s %s %s\r\n", valueOrDefault(r.Method, http.MethodGet), uri, proto)
+	if host != "" {
+		_, _ = fmt.Fprintf(b, "Host: %s\r\n", host)
+	}
+	d.writeHeader(b, r.Header)
+	d.writeBody(b, r.Header.Get("Content-Type"), c)
+
+	slog.Log(ctx, level, "HTTP REQUEST", "dump", b.String())
+}
+
+// Response prints out the HTTP response with the body captured by c, c may be nil.
+func (d *Dumper) Response(ctx context.Context, r *http.Response, c *Capture, level slog.Level) {
+	b := new(bytes.Buffer)
+
+	proto := r.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	status := r.Status
+	if status == "" {
+		status = strconv.Itoa(r.StatusCode) + " " + http.StatusText(r.StatusCode)
+	}
+
+	_, _ = fmt.Fprintf(b, "%s %s\r\n", proto, status)
+	d.writeHeader(b, r.Header)
+	d.writeBody(b, r.Header.Get("Content-Type"), c)
+
+	slog.Log(ctx, level, "HTTP RESPONSE", "dump", b.String())
+}
+
+func (d *Dumper) writeHeader(b *bytes.Buffer, h http.Header) {
+	names := d.RedactHeaders
+	if names == nil {
+		names = DefaultRedactHeaders
+	}
+
+	h = h.Clone()
+	for _, name := range names {
+		if values := h.Values(name); len(values) != 0 {
+			h.Set(name, redacted)
+		}
+	}
+
+	_ = h.Write(b)
+	b.WriteString("\r\n")
+}
+
+func (d *Dumper) writeBody(b *bytes.Buffer, contentType string, c *Capture) {
+	if c == nil || c.max == 0 {
+		return
+	}
+
+	body, size, truncated := c.snapshot()
+	if size == 0 {
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch {
+	case isJSON(mediaType):
+		var cut bool
+		body, cut = d.redactJSON(body, truncated)
+		truncated = truncated || cut
+		if d.Pretty && !truncated {
+			indented := new(bytes.Buffer)
+			if json.Indent(indented, body, "", "  ") == nil {
+				body = indented.Bytes()
+			}
+		}
+	case mediaType == "application/x-www-form-urlencoded":
+		body = d.redactForm(body, truncated)
+	case !isText(mediaType, body):
+		_, _ = fmt.Fprintf(b, "[%d bytes of %s]", size, valueOrDefault(mediaType, "binary data"))
+		return
+	}
+
+	b.Write(body)
+	if truncated {
+		b.WriteString("\n[truncated]")
+	}
+}
+
+// redactJSON replaces the values of RedactFields keys at any depth, including objects and arrays, entirely.
+// If the body is truncated in the middle of such a value, or is not valid JSON, the body is cut before
+// the part that cannot be redacted, cut reports it.
+func (d *Dumper) redactJSON(body []byte, truncated bool) (_ []byte, cut bool) {
+	if len(d.RedactFields) == 0 {
+		return body, false
+	}
+
+	// frame is an open object or array, key reports whether a key of the object is expected
+	type frame struct{ object, key bool }
+	var frames []frame
+
+	out := make([]byte, 0, len(body))
+	var copied int64 // body before copied is already in out
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+
+	for {
+		offset := dec.InputOffset()
+
+		token, err := dec.Token()
+		if err == io.EOF {
+			return append(out, body[copied:]...), false
+		}
+		if err != nil {
+			if truncated && err == io.ErrUnexpectedEOF {
+				return append(out, body[copied:]...), false
+			}
+			// the rest cannot be parsed, so it cannot be redacted
+			return append(out, body[copied:offset]...), true
+		}
+
+		var top *frame
+		if len(frames) != 0 {
+			top = &frames[len(frames)-1]
+		}
+
+		if key, ok := token.(string); ok && top != nil && top.key {
+			top.key = false
+			if !d.redactField(key) {
+				continue
+			}
+
+			start := valueStart(body, dec.InputOffset())
+			out = append(append(out, body[copied:start]...), `"`+redacted+`"`...)
+
+			if err = skipValue(dec); err != nil {
+				// the end of the value is unknown, so the body is cut at the field
+				return out, true
+			}
+			copied = dec.InputOffset()
+			top.key = true
+			continue
+		}
+
+		switch token {
+		case json.Delim('}'), json.Delim(']'):
+			frames = frames[:len(frames)-1]
+			continue
+		}
+
+		if top != nil && top.object {
+			top.key = true // the value is read, the next token is a key
+		}
+
+		switch token {
+		case json.Delim('{'):
+			frames = append(frames, frame{object: true, key: true})
+		case json.Delim('['):
+			frames = append(frames, frame{})
+		}
+	}
+}
+
+// redactField reports whether the key is one of the RedactFields.
+func (d *Dumper) redactField(key string) bool {
+	for _, field := range d.RedactFields {
+		if strings.EqualFold(key, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// valueStart returns the offset of the value following the key which ends at offset.
+func valueStart(body []byte, offset int64) int64 {
+	for offset < int64(len(body)) {
+		switch body[offset] {
+		case ' ', '\t', '\r', '\n', ':':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+// skipValue reads the next value including nested objects and arrays.
+func skipValue(dec *json.Decoder) error {
+	var depth int
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// redactForm replaces the values of RedactFields fields of a form.
+func (d *Dumper) redactForm(body []byte, truncated bool) []byte {
+	if len(d.RedactFields) == 0 {
+		return body
+	}
+
+	pairs := bytes.Split(body, []byte("&"))
+	for i, pair := range pairs {
+		key, _, found := bytes.Cut(pair, []byte("="))
+		if !found && !(truncated && i == len(pairs)-1) {
+			continue
+		}
+		name, err := url.QueryUnescape(string(key))
+		if err != nil {
+			continue
+		}
+		for _, field := range d.RedactFields {
+			if strings.EqualFold(name, field) {
+				pairs[i] = append(append(key, '='), url.QueryEscape(redacted)...)
+				break
+			}
+		}
+	}
+
+	return bytes.Join(pairs, []byte("&"))
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// isText reports whether the body can be printed as is.
+func isText(mediaType string, body []byte) bool {
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+xml"),
+		mediaType == "application/xml",
+		mediaType == "application/javascript",
+		mediaType == "application/x-ndjson":
+		return true
+	case mediaType == "":
+		return strings.HasPrefix(http.DetectContentType(body), "text/")
+	default:
+		return false
+	}
+}
+
+func valueOrDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+// Capture keeps the first bytes of a body and counts the rest. It is safe for concurrent use.
+// Capture is an io.Writer, so it can be used with io.TeeReader or httpserver.ResponseWriter.Tee.
+type Capture struct {
+	mu   sync.Mutex
+	buf  []byte
+	max  int
+	size int64
+}
+
+// Write keeps the data up to the limit, it never fails.
+func (c *Capture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if room := c.max - len(c.buf); room > 0 {
+		c.buf = append(c.buf, p[:min(room, len(p))]...)
+	}
+	c.size += int64(len(p))
+
+	return len(p), nil
+}
+
+// Tee returns a body which writes to c everything read from rc.
+func (c *Capture) Tee(rc io.ReadCloser) io.ReadCloser {
+	return &teeBody{ReadCloser: rc, r: io.TeeReader(rc, c)}
+}
+
+// Bytes returns a copy of the kept data.
+func (c *Capture) Bytes() []byte {
+	b, _, _ := c.snapshot()
+	return b
+}
+
+// Size returns the number of bytes written to c.
+func (c *Capture) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.size
+}
+
+func (c *Capture) snapshot() ([]byte, int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return bytes.Clone(c.buf), c.size, c.size > int64(len(c.buf))
+}
+
+// prefetch reads the body up to the limit into c and restores it.
+// One extra byte is read to know whether the body is truncated.
+func (c *Capture) prefetch(body *io.ReadCloser) error {
+	rc := *body
+
+	b, err := io.ReadAll(io.LimitReader(rc, int64(c.max)+1))
+	if err != nil {
+		return err
+	}
+
+	_, _ = c.Write(b)
+	*body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(b), rc), rc}
+
+	return nil
+}
+
+type teeBody struct {
+	io.ReadCloser
+	r io.Reader
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
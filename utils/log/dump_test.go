@@ -0,0 +1,161 @@
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/easy-techno-lab/proton/utils/log"
+)
+
+func equal(t *testing.T, exp, got any) {
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("Not equal:\nexp: %v\ngot: %v", exp, got)
+	}
+}
+
+func dumpRecords(buf *bytes.Buffer) []string {
+	var records []string
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var record map[string]any
+		_ = dec.Decode(&record)
+		if dump, ok := record["dump"].(string); ok {
+			records = append(records, dump)
+		}
+	}
+	return records
+}
+
+func TestDumper(t *testing.T) {
+	var tests = []struct {
+		name        string
+		dumper      *log.Dumper
+		contentType string
+		header      http.Header
+		body        string
+		exp         string
+	}{
+		{
+			name:        "default headers are redacted",
+			dumper:      new(log.Dumper),
+			contentType: "text/plain",
+			header:      http.Header{"Authorization": {"Bearer token"}, "Cookie": {"a=b"}, "X-Custom": {"value"}},
+			body:        "hello",
+			exp: "POST /path HTTP/1.1\r\nHost: example.com\r\nAuthorization: [REDACTED]\r\nContent-Type: text/plain\r\n" +
+				"Cookie: [REDACTED]\r\nX-Custom: value\r\n\r\nhello",
+		},
+		{
+			name:        "JSON fields are redacted and pretty printed",
+			dumper:      &log.Dumper{RedactFields: []string{"password", "token"}, Pretty: true},
+			contentType: "application/json; charset=utf-8",
+			body:        `{"user":"admin","Password":"se\"cret","nested":{"token":42}}`,
+			exp: "POST /path HTTP/1.1\r\nHost: example.com\r\nContent-Type: application/json; charset=utf-8\r\n\r\n" +
+				"{\n  \"user\": \"admin\",\n  \"Password\": \"[REDACTED]\",\n  \"nested\": {\n    \"token\": \"[REDACTED]\"\n  }\n}",
+		},
+		{
+			name:        "truncated JSON is redacted",
+			dumper:      &log.Dumper{MaxBody: 30, RedactFields: []string{"password"}, Pretty: true},
+			contentType: "application/json",
+			body:        `{"user":"admin","password":"secret-secret"}`,
+			exp: "POST /path HTTP/1.1\r\nHost: example.com\r\nContent-Type: application/json\r\n\r\n" +
+				"{\"user\":\"admin\",\"password\":\"[REDACTED]\"\n[truncated]",
+		},
+		{
+			name:        "JSON object and array values are redacted",
+			dumper:      &log.Dumper{RedactFields: []string{"password", "token"}},
+			contentType: "application/json",
+			body:        `{"password":{"v":"secret","w":[1,{"x":2}]},"token":["a","b"],"user":"admin"}`,
+			exp: "POST /path HTTP/1.1\r\nHost: example.com\r\nContent-Type: application/json\r\n\r\n" +
+				`{"password":"[REDACTED]","token":"[REDACTED]","user":"admin"}`,
+		},
+		{
+			name:        "truncated JSON is cut at the field",
+			dumper:      &log.Dumper{MaxBody: 32, RedactFields: []string{"password"}},
+			contentType: "application/json",
+			body:        `{"user":"admin","password":{"v":"secret"}}`,
+			exp: "POST /path HTTP/1.1\r\nHost: example.com\r\nContent-Type: application/json\r\n\r\n" +
+				"{\"user\":\"admin\",\"password\":\"[REDACTED]\"\n[truncated]",
+		},
+		{
+			name:        "truncated JSON is kept after the fields",
+			dumper:      &log.Dumper{MaxBody: 20, RedactFields: []string{"password"}},
+			contentType: "application/json",
+			body:        `{"password":"x","user":"administrator"}`,
+			exp: "POST /path HTTP/1.1\r\nHost: example.com\r\nContent-Type: application/json\r\n\r\n" +
+				"{\"password\":\"[REDACTED]\",\"use\n[truncated]",
+		},
+		{
+			name:        "invalid JSON is cut before the error",
+			dumper:      &log.Dumper{RedactFields: []string{"password"}},
+			contentType: "application/json",
+			body:        `{"user":"admin",,"password":"secret"}`,
+			exp: "POST /path HTTP/1.1\r\nHost: example.com\r\nContent-Type: application/json\r\n\r\n" +
+				"{\"user\":\"admin\"\n[truncated]",
+		},
+		{
+			name:        "form fields are redacted",
+			dumper:      &log.Dumper{RedactFields: []string{"client_secret"}},
+			contentType: "application/x-www-form-urlencoded",
+			body:        "grant_type=client_credentials&client_secret=secret",
+			exp: "POST /path HTTP/1.1\r\nHost: example.com\r\nContent-Type: application/x-www-form-urlencoded\r\n\r\n" +
+				"grant_type=client_credentials&client_secret=%5BREDACTED%5D",
+		},
+		{
+			name:        "binary body is not printed",
+			dumper:      new(log.Dumper),
+			contentType: "application/octet-stream",
+			body:        "\x00\x01\x02",
+			exp: "POST /path HTTP/1.1\r\nHost: example.com\r\nContent-Type: application/octet-stream\r\n\r\n" +
+				"[3 bytes of application/octet-stream]",
+		},
+		{
+			name:        "body is disabled",
+			dumper:      &log.Dumper{MaxBody: -1},
+			contentType: "text/plain",
+			body:        "hello",
+			exp:         "POST /path HTTP/1.1\r\nHost: example.com\r\nContent-Type: text/plain\r\n\r\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			prev := slog.Default()
+			slog.SetDefault(slog.New(slog.NewJSONHandler(buf, nil)))
+			defer slog.SetDefault(prev)
+
+			r := httptest.NewRequest(http.MethodPost, "/path", strings.NewReader(test.body))
+			for key, values := range test.header {
+				r.Header[key] = values
+			}
+			r.Header.Set("Content-Type", test.contentType)
+
+			test.dumper.DumpRequest(context.Background(), r, slog.LevelInfo)
+
+			// the body is restored
+			b, err := io.ReadAll(r.Body)
+			equal(t, nil, err)
+			equal(t, test.body, string(b))
+
+			equal(t, []string{test.exp}, dumpRecords(buf))
+		})
+	}
+}
+
+func TestCapture_Tee(t *testing.T) {
+	c := (&log.Dumper{MaxBody: 4}).Capture()
+
+	b, err := io.ReadAll(c.Tee(io.NopCloser(strings.NewReader("streamed body"))))
+	equal(t, nil, err)
+	equal(t, "streamed body", string(b))
+	equal(t, "stre", string(c.Bytes()))
+	equal(t, int64(13), c.Size())
+}
@@ -5,19 +5,20 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
-	"net/http/httputil"
 )
 
 type contextKey int
 
 const (
 	TraceCtxKey contextKey = iota + 1
+	SpanCtxKey
+	RequestIDCtxKey
 
 	maxBody = 1 << 14 // 16KiB
 )
 
-// TraceHandler allows the slog to add a trace ID to logs from the context.
-// To add a trace ID to the context, use TraceCtxKey:
+// TraceHandler allows the slog to add a trace ID, a span ID and a request ID to logs from the context.
+// To add them to the context, use TraceCtxKey, SpanCtxKey and RequestIDCtxKey:
 //
 //	ctx = context.WithValue(ctx, log.TraceCtxKey, 'put_trace_id_here')
 type TraceHandler struct {
@@ -29,31 +30,27 @@ func (h TraceHandler) Handle(ctx context.Context, r slog.Record) error {
 		r.Add("trace_id", slog.StringValue(traceID))
 	}
 
+	if spanID, ok := ctx.Value(SpanCtxKey).(string); ok {
+		r.Add("span_id", slog.StringValue(spanID))
+	}
+
+	if requestID, ok := ctx.Value(RequestIDCtxKey).(string); ok {
+		r.Add("request_id", slog.StringValue(requestID))
+	}
+
 	return h.Handler.Handle(ctx, r)
 }
 
 // DumpHttpRequest dumps the HTTP request and prints out.
+// Default headers are redacted, see Dumper for the configuration.
 func DumpHttpRequest(ctx context.Context, r *http.Request, level slog.Level) {
-	dumpFunc := httputil.DumpRequestOut
-	if r.URL.Scheme == "" || r.URL.Host == "" {
-		dumpFunc = httputil.DumpRequest
-	}
-	b, err := dumpFunc(r, r.ContentLength < maxBody)
-	if err != nil {
-		slog.ErrorContext(ctx, "HTTP REQUEST", "error", err)
-		return
-	}
-	slog.Log(ctx, level, "HTTP REQUEST", "dump", string(b))
+	defaultDumper.DumpRequest(ctx, r, level)
 }
 
 // DumpHttpResponse dumps the HTTP response and prints out.
+// Default headers are redacted, see Dumper for the configuration.
 func DumpHttpResponse(ctx context.Context, r *http.Response, level slog.Level) {
-	b, err := httputil.DumpResponse(r, r.ContentLength < maxBody)
-	if err != nil {
-		slog.ErrorContext(ctx, "HTTP RESPONSE", "error", err)
-		return
-	}
-	slog.Log(ctx, level, "HTTP RESPONSE", "dump", string(b))
+	defaultDumper.DumpResponse(ctx, r, level)
 }
 
 // Closer calls the Close method, if the closure occurred with an error, it prints out.